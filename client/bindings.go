@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/eiannone/keyboard"
+)
+
+// Actions keys are bound to
+const (
+	ACTION_UP         = "up"
+	ACTION_DOWN       = "down"
+	ACTION_LEFT       = "left"
+	ACTION_RIGHT      = "right"
+	ACTION_READY      = "ready"
+	ACTION_START      = "start"
+	ACTION_COMMAND    = "command"
+	ACTION_CHAT       = "chat"
+	ACTION_SCOREBOARD = "scoreboard"
+	ACTION_STATS      = "stats"
+	ACTION_SPECTATE   = "spectate"
+	ACTION_BINDINGS   = "bindings"
+	ACTION_QUIT       = "quit"
+	// Keys of the second player when two play at the keyboard
+	ACTION_GUEST_UP    = "guest-up"
+	ACTION_GUEST_DOWN  = "guest-down"
+	ACTION_GUEST_LEFT  = "guest-left"
+	ACTION_GUEST_RIGHT = "guest-right"
+)
+
+// Moves sent to the server for every move action
+var ACTION_MOVES = map[string]rune{
+	ACTION_UP:          '^',
+	ACTION_DOWN:        'v',
+	ACTION_LEFT:        '<',
+	ACTION_RIGHT:       '>',
+	ACTION_GUEST_UP:    '^',
+	ACTION_GUEST_DOWN:  'v',
+	ACTION_GUEST_LEFT:  '<',
+	ACTION_GUEST_RIGHT: '>',
+}
+
+// Actions in the order the bindings screen lists them
+var ACTIONS = []struct {
+	Name        string
+	Description string
+}{
+	{ACTION_UP, "Move up"},
+	{ACTION_DOWN, "Move down"},
+	{ACTION_LEFT, "Move left"},
+	{ACTION_RIGHT, "Move right"},
+	{ACTION_READY, "Ready or unready in the lobby"},
+	{ACTION_START, "Start the match, host only"},
+	{ACTION_COMMAND, "Type a command"},
+	{ACTION_CHAT, "Type a chat message"},
+	{ACTION_SCOREBOARD, "Show or hide the scoreboard"},
+	{ACTION_STATS, "Show or hide ping, loss and tick rate"},
+	{ACTION_SPECTATE, "Watch the next snake while dead"},
+	{ACTION_BINDINGS, "Show or hide this screen"},
+	{ACTION_QUIT, "Leave the room"},
+	{ACTION_GUEST_UP, "Move the second player up"},
+	{ACTION_GUEST_DOWN, "Move the second player down"},
+	{ACTION_GUEST_LEFT, "Move the second player left"},
+	{ACTION_GUEST_RIGHT, "Move the second player right"},
+}
+
+// Names of the keys that aren't characters
+var KEY_NAMES = map[keyboard.Key]string{
+	keyboard.KeyArrowUp:    "up",
+	keyboard.KeyArrowDown:  "down",
+	keyboard.KeyArrowLeft:  "left",
+	keyboard.KeyArrowRight: "right",
+	keyboard.KeyEsc:        "esc",
+	keyboard.KeyEnter:      "enter",
+	keyboard.KeyTab:        "tab",
+	keyboard.KeySpace:      "space",
+	keyboard.KeyHome:       "home",
+	keyboard.KeyEnd:        "end",
+	keyboard.KeyPgup:       "pgup",
+	keyboard.KeyPgdn:       "pgdn",
+	keyboard.KeyInsert:     "insert",
+	keyboard.KeyDelete:     "delete",
+	keyboard.KeyF1:         "f1",
+	keyboard.KeyF2:         "f2",
+	keyboard.KeyF3:         "f3",
+	keyboard.KeyF4:         "f4",
+	keyboard.KeyF5:         "f5",
+	keyboard.KeyF6:         "f6",
+	keyboard.KeyF7:         "f7",
+	keyboard.KeyF8:         "f8",
+	keyboard.KeyF9:         "f9",
+	keyboard.KeyF10:        "f10",
+	keyboard.KeyF11:        "f11",
+	keyboard.KeyF12:        "f12",
+}
+
+// Bindings are the key names bound to every action, the first key of an
+// action is the one shown in help
+type Bindings map[string][]string
+
+var DEFAULT_BINDINGS = Bindings{
+	ACTION_UP:         {"w", "k", "up"},
+	ACTION_DOWN:       {"s", "j", "down"},
+	ACTION_LEFT:       {"a", "h", "left"},
+	ACTION_RIGHT:      {"d", "l", "right"},
+	ACTION_READY:      {"r"},
+	ACTION_START:      {"g"},
+	ACTION_COMMAND:    {"/"},
+	ACTION_CHAT:       {"t"},
+	ACTION_SCOREBOARD: {"tab"},
+	ACTION_STATS:      {"i", "f2"},
+	ACTION_SPECTATE:   {"n"},
+	ACTION_BINDINGS:   {"?", "f1"},
+	ACTION_QUIT:       {"esc"},
+	// Arrows move the second player when there is one, they move the first
+	// player otherwise
+	ACTION_GUEST_UP:    {"up"},
+	ACTION_GUEST_DOWN:  {"down"},
+	ACTION_GUEST_LEFT:  {"left"},
+	ACTION_GUEST_RIGHT: {"right"},
+}
+
+var (
+	keyBindings    = DEFAULT_BINDINGS
+	bindingsFile   string // File keyBindings were read from
+	showScoreboard = true // Guarded by stateMutex
+	showBindings   bool   // Guarded by stateMutex
+	spectateID     uint32 // Snake the camera follows while we are dead, guarded by stateMutex
+)
+
+// keyName returns the name of a key read from the keyboard, its character
+// for the keys that have one
+func keyName(char rune, key keyboard.Key) string {
+	if char != 0 {
+		return string(char)
+	}
+	return KEY_NAMES[key]
+}
+
+func validKeyName(name string) bool {
+	if utf8.RuneCountInString(name) == 1 {
+		return true
+	}
+	for _, keyName := range KEY_NAMES {
+		if keyName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Action returns the action bound to a key, or nothing. The keys of the
+// second player come first when two play at the keyboard, and do nothing
+// otherwise.
+func (bindings Bindings) Action(key string, twoPlayers bool) string {
+	if twoPlayers {
+		for _, action := range ACTIONS {
+			if guestAction(action.Name) && slices.Contains(bindings[action.Name], key) {
+				return action.Name
+			}
+		}
+	}
+	for _, action := range ACTIONS {
+		if !guestAction(action.Name) && slices.Contains(bindings[action.Name], key) {
+			return action.Name
+		}
+	}
+	return ""
+}
+
+func guestAction(action string) bool {
+	return strings.HasPrefix(action, "guest-")
+}
+
+// Key returns the key shown in help for an action
+func (bindings Bindings) Key(action string) string {
+	keys := bindings[action]
+	if len(keys) == 0 {
+		return "none"
+	}
+	return keyLabel(keys[0])
+}
+
+// keyLabel capitalises the names of the keys that aren't characters
+func keyLabel(name string) string {
+	if utf8.RuneCountInString(name) == 1 {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// bindingsPath returns the file the bindings are read from, SNAKE_KEYS or
+// snake/keys.conf in the config directory of the user
+func bindingsPath() string {
+	if path := os.Getenv("SNAKE_KEYS"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "snake", "keys.conf")
+}
+
+// LoadBindings reads the bindings file over the default bindings. A missing
+// file only means the defaults are kept.
+func LoadBindings(path string) (Bindings, error) {
+	if path == "" {
+		return DEFAULT_BINDINGS, nil
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return DEFAULT_BINDINGS, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadBindings(file, path)
+}
+
+// ReadBindings reads "action: key key..." lines, every line replacing the
+// keys of its action:
+//
+//	// Arrow keys only
+//	up: up
+//	down: down
+//	chat: enter
+//
+// Keys are a character or a name of KEY_NAMES. A key bound by the file is
+// taken from the actions it's bound to by default. The keys of the second
+// player may also be bound to actions of the first one.
+func ReadBindings(file io.Reader, path string) (Bindings, error) {
+	type boundKey struct {
+		Guest bool
+		Key   string
+	}
+	bindings := Bindings{}
+	boundBy := make(map[boundKey]string) // Action a key is bound to by the file
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		action, value, found := strings.Cut(line, ":")
+		if !found {
+			return nil, fmt.Errorf("%s:%d: expected action: keys", path, lineNum)
+		}
+		action = strings.TrimSpace(action)
+		if _, exist := DEFAULT_BINDINGS[action]; !exist {
+			return nil, fmt.Errorf("%s:%d: unknown action %q", path, lineNum, action)
+		}
+		keys := strings.Fields(value)
+		for _, key := range keys {
+			if !validKeyName(key) {
+				return nil, fmt.Errorf("%s:%d: unknown key %q", path, lineNum, key)
+			}
+			bound := boundKey{guestAction(action), key}
+			if other, found := boundBy[bound]; found && other != action {
+				return nil, fmt.Errorf("%s:%d: %s is already bound to %s", path, lineNum, key, other)
+			}
+			boundBy[bound] = action
+		}
+		bindings[action] = keys
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for action, keys := range DEFAULT_BINDINGS {
+		if _, set := bindings[action]; set {
+			continue
+		}
+		for _, key := range keys {
+			if _, bound := boundBy[boundKey{guestAction(action), key}]; !bound {
+				bindings[action] = append(bindings[action], key)
+			}
+		}
+	}
+	return bindings, nil
+}
+
+// bindingsScreen returns the lines listing the bindings, shown in place of
+// the map
+func bindingsScreen() []string {
+	lines := []string{"Key bindings", ""}
+	for _, action := range ACTIONS {
+		labels := []string{}
+		for _, key := range keyBindings[action.Name] {
+			labels = append(labels, keyLabel(key))
+		}
+		if len(labels) == 0 {
+			labels = append(labels, "none")
+		}
+		lines = append(lines, fmt.Sprintf("%-12s %-16s %s", action.Name, strings.Join(labels, " "), action.Description))
+	}
+	if bindingsFile != "" {
+		lines = append(lines, "", "Change them in "+bindingsFile)
+	}
+	return append(lines, fmt.Sprintf("Press %s to go back", keyBindings.Key(ACTION_BINDINGS)))
+}
+
+// spectateNext moves the camera to the next living snake, in the order of
+// their IDs
+func spectateNext() {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	alive := []Player{}
+	for _, player := range lastResponse.Players {
+		if len(player.Snake) != 0 && player.UserID != userID {
+			alive = append(alive, player)
+		}
+	}
+	if len(alive) == 0 {
+		statusMessage = "Nobody to spectate"
+		return
+	}
+	sort.Slice(alive, func(i int, j int) bool { return alive[i].UserID < alive[j].UserID })
+	next := alive[0]
+	for _, player := range alive {
+		if player.UserID > spectateID {
+			next = player
+			break
+		}
+	}
+	spectateID = next.UserID
+	statusMessage = "Spectating " + next.Username
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadBindings(t *testing.T) {
+	bindings, err := ReadBindings(strings.NewReader("// Arrows only\nup: up\ndown: down\nchat: enter k\n"), "keys.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		ACTION_UP:   {"up"},
+		ACTION_DOWN: {"down"},
+		ACTION_CHAT: {"enter", "k"},
+		ACTION_LEFT: {"a", "h", "left"},
+		ACTION_QUIT: {"esc"},
+		// The arrows of the second player aren't taken by the first one
+		ACTION_GUEST_UP: {"up"},
+	}
+	for action, keys := range want {
+		if !reflect.DeepEqual(bindings[action], keys) {
+			t.Errorf("%s bound to %v, want %v", action, bindings[action], keys)
+		}
+	}
+	if action := bindings.Action("k", false); action != ACTION_CHAT {
+		t.Errorf("k bound to %q, want chat", action)
+	}
+	if action := bindings.Action("up", false); action != ACTION_UP {
+		t.Errorf("up bound to %q playing alone, want up", action)
+	}
+	if action := bindings.Action("up", true); action != ACTION_GUEST_UP {
+		t.Errorf("up bound to %q playing two, want guest-up", action)
+	}
+
+	errors := map[string]string{
+		"up w":                                "keys.conf:1: expected action: keys",
+		"jump: space":                         `keys.conf:1: unknown action "jump"`,
+		"up: shift":                           `keys.conf:1: unknown key "shift"`,
+		"up: w\ndown: w\n":                    "keys.conf:2: w is already bound to up",
+		"up: i\nguest-down: i\nguest-up: i\n": "keys.conf:3: i is already bound to guest-down",
+	}
+	for file, want := range errors {
+		if _, err := ReadBindings(strings.NewReader(file), "keys.conf"); err == nil || err.Error() != want {
+			t.Errorf("%q: got error %v, want %s", file, err, want)
+		}
+	}
+}
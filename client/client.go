@@ -5,59 +5,50 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	crand "crypto/rand"
-	"crypto/rsa"
-	"crypto/sha256"
-	"crypto/x509"
 	"encoding/binary"
 	"encoding/json"
+	"engine"
+	"flag"
 	"fmt"
 	"io"
 	"log"
-	"net"
 	"os"
-	"os/exec"
 	"os/signal"
-	"runtime"
+	"slices"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
+	"unicode/utf8"
 
 	"github.com/eiannone/keyboard"
 )
 
 const (
-	SERVER_IP   = "127.0.0.1"
-	UDP_PORT    = "1566"
-	TCP_PORT    = "1567"
-	UDP         = "udp4"
-	TCP         = "tcp4"
-	BUFFER_SIZE = 2048
+	SERVER_IP       = "127.0.0.1"
+	UDP_PORT        = "1566"
+	TCP_PORT        = "1567"
+	UDP             = "udp4"
+	TCP             = "tcp4"
+	BUFFER_SIZE     = 2048
+	UDP_BUFFER_SIZE = 65535 // Snapshots of big maps don't fit in BUFFER_SIZE
 )
 
-type Location struct {
-	X uint8
-	Y uint8
-}
-
-type Player struct {
-	UserID     uint32
-	Move       rune
-	Snake      []Location
-	Point      uint32
-	Username   string
-	SnakeShape rune
-}
-
-type CommandRequest struct {
-	UserID     uint32
-	JoinRoom   bool
-	RoomID     uint8
-	ExitRoom   bool
-	Quit       bool
-	Username   [5]rune
-	SnakeShape rune
-}
+type (
+	Location        = engine.Location
+	Player          = engine.Player
+	RoomSettings    = engine.RoomSettings
+	CommandRequest  = engine.CommandRequest
+	MoveRequest     = engine.MoveRequest
+	DisplayResponse = engine.DisplayResponse
+	Zone            = engine.Zone
+	Match           = engine.Match
+	Result          = engine.Result
+	Event           = engine.Event
+	Food            = engine.Food
+)
 
 type CommandResponse struct {
 	IsSuccess bool
@@ -66,77 +57,40 @@ type CommandResponse struct {
 	Quit      bool
 }
 
-type MoveRequest struct {
-	UserID uint32
-	Move   rune
-}
-
-type DisplayResponse struct {
-	Players []Player
-	Foods   []Location
-}
+const KILL_FEED_SIZE = 5
 
 var (
 	userID         uint32
 	isPlaying      bool
 	isPlayingMutex sync.Mutex
-	symmetricKey   []byte
 	userName       string
+	kicked         bool     // Set when the host kicked us, guarded by isPlayingMutex
+	killFeed       []string // Latest events, oldest first, guarded by stateMutex
+	screen         = NewScreen(os.Stdout)
 )
 
 func main() {
 	isPlaying = false
 
-	remoteTCPAddr, err := net.ResolveTCPAddr(TCP, net.JoinHostPort(SERVER_IP, TCP_PORT))
-	if err != nil {
-		log.Fatalln(err)
-	}
-	tcpSocket, err := net.DialTCP(TCP, nil, remoteTCPAddr)
-	if err != nil {
-		log.Fatalln(err)
+	flag.BoolVar(&offline, "offline", false, "play without a server, in rooms run by the client")
+	flag.IntVar(&offlineBots, "bots", 0, fmt.Sprintf("bots added to rooms created offline, %d at most", OFFLINE_MAX_BOTS))
+	mapsDir := flag.String("maps", MAPS_DIR, "directory of the maps of offline rooms")
+	flag.Parse()
+	if offline {
+		offlineBots = min(max(offlineBots, 0), OFFLINE_MAX_BOTS)
+		offlineMaps = engine.LoadMaps(*mapsDir)
 	}
 
-	// Get public key
-	pubKeyBuffer := make([]byte, BUFFER_SIZE)
-	pubKeyBuffLength, _ := tcpSocket.Read(pubKeyBuffer)
-	pubKeyTemp, err := x509.ParsePKIXPublicKey(pubKeyBuffer[:pubKeyBuffLength])
+	bindingsFile = bindingsPath()
+	bindings, err := LoadBindings(bindingsFile)
 	if err != nil {
 		log.Fatalln(err)
 	}
-	pubKey := pubKeyTemp.(*rsa.PublicKey)
+	keyBindings = bindings
 
-	// Send symmetric Key
-	symmetricKey = make([]byte, 32)
-	if _, err := io.ReadFull(crand.Reader, symmetricKey); err != nil {
-		log.Fatalln(err)
-	}
-
-	encryptedSKey, err := rsa.EncryptOAEP(sha256.New(), crand.Reader, pubKey, symmetricKey, nil)
-	if err != nil {
-		log.Fatalln(err)
-	}
-	tcpSocket.Write(encryptedSKey)
-
-	// Ambil userId
-	userIdBuffer := make([]byte, BUFFER_SIZE)
-	length, _ := tcpSocket.Read(userIdBuffer)
-	userID = binary.BigEndian.Uint32(decryptMessage(userIdBuffer[:length]))
-
-	remoteUdpAddr, err := net.ResolveUDPAddr(UDP, net.JoinHostPort(SERVER_IP, UDP_PORT))
-	if err != nil {
-		log.Fatalln(err)
-	}
-	udpSocket, err := net.DialUDP(UDP, nil, remoteUdpAddr)
-	if err != nil {
-		log.Fatalln(err)
-	}
-
-	// Send udp address
-	udpAddrBuffer := new(bytes.Buffer)
-	udpAddrBuffer.WriteString(udpSocket.LocalAddr().String())
-	tcpSocket.Write(encryptMessage(udpAddrBuffer.Bytes()))
-
-	defer closeConn(tcpSocket, udpSocket)
+	session = Connect()
+	userID = session.UserID
+	defer session.Close()
 
 	// Handle SIGINT and SIGTERM
 	sigChannel := make(chan os.Signal, 1)
@@ -144,24 +98,62 @@ func main() {
 
 	go func() {
 		<-sigChannel
-		closeConn(tcpSocket, udpSocket)
+		screen.Leave()
+		stateMutex.Lock()
+		locals := localSessions()
+		stateMutex.Unlock()
+		for _, local := range locals {
+			local.Close()
+		}
 		os.Exit(0)
 	}()
 
+	go receiveSnapshots(session)
+	go sendPings(session)
+	resized := make(chan os.Signal, 1)
+	notifyResize(resized)
 	clearScreen()
 	for {
 		if isPlaying {
-			go readKeyboard(tcpSocket, udpSocket)
+			keyboardDone := make(chan bool)
+			go func() {
+				readKeyboard()
+				keyboardDone <- true
+			}()
+			screen.Enter()
+			frames := time.NewTicker(time.Second / FRAME_RATE)
 			for {
+				// A resized terminal is drawn again right away
+				select {
+				case <-frames.C:
+				case <-resized:
+				}
 				isPlayingMutex.Lock()
-				if !isPlaying {
-					isPlayingMutex.Unlock()
-					clearScreen()
+				playing := isPlaying
+				isPlayingMutex.Unlock()
+				if !playing {
 					break
 				}
-				draw(udpSocket)
-				isPlayingMutex.Unlock()
+				drawFrame()
+			}
+			frames.Stop()
+
+			isPlayingMutex.Lock()
+			if kicked {
+				screen.Draw("You were kicked from the room, press any key")
 			}
+			isPlayingMutex.Unlock()
+			// Wait for the keyboard to be closed before reading lines again
+			<-keyboardDone
+			stateMutex.Lock()
+			second := guest
+			guest = nil
+			stateMutex.Unlock()
+			if second != nil {
+				second.Close()
+			}
+			screen.Leave()
+			clearScreen()
 		} else {
 			var roomNumStr string
 			fmt.Print("Enter room number (1-255): ")
@@ -169,38 +161,86 @@ func main() {
 			roomNum, _ := strconv.Atoi(roomNumStr)
 
 			if roomNum > 0 && roomNum < 256 {
+				var nameString string
 				fmt.Print("Enter username (5 char max): ")
-				fmt.Scanln(&userName)
-				if len(userName) == 0 {
+				fmt.Scanln(&nameString)
+				name, err := normalizeName(nameString)
+				if err != nil {
 					clearScreen()
-					fmt.Println("username must not be blank")
+					fmt.Println(err)
 					continue
-				} else if len(userName) > 5 {
-					userName = userName[:5]
 				}
+				userName = name
 
 				var shapeString string
 				fmt.Print("Enter snake shape: ")
 				fmt.Scanln(&shapeString)
-				if len(shapeString) == 0 {
+				shape, err := normalizeShape(shapeString)
+				if err != nil {
 					clearScreen()
-					fmt.Println("snake shape must not be blank")
+					fmt.Println(err)
 					continue
 				}
 
-				runeUsername := make([]rune, 5)
-				tempRuneUsername := []rune(userName)
-				copy(runeUsername, tempRuneUsername)
-				fmt.Println(tempRuneUsername)
-				commandRequest := CommandRequest{userID, true, uint8(roomNum), false, false, [5]rune(runeUsername), rune(shapeString[0])}
-				encodedCommandRequest := encodeCommandRequest(commandRequest)
-				tcpSocket.Write(encodedCommandRequest)
-
-				receiveBuffer := make([]byte, BUFFER_SIZE)
-				receiveLength, _ := tcpSocket.Read(receiveBuffer)
-				response := decodeCommandResponse(receiveBuffer[:receiveLength])
+				team := uint8(readNumber("Enter team (blank for any team): ", len(TEAM_NAMES)))
+				color := uint8(readNumber("Enter snake colour (1-255, blank for any colour): ", 255))
+				var guestName string
+				fmt.Print("Enter second player username to play two at this keyboard (blank to play alone): ")
+				fmt.Scanln(&guestName)
+				if strings.TrimSpace(guestName) != "" {
+					if guestName, err = normalizeName(guestName); err != nil {
+						clearScreen()
+						fmt.Println("second player", err)
+						continue
+					}
+				}
+				settings := readRoomSettings()
+
+				commandRequest := CommandRequest{
+					JoinRoom:   true,
+					RoomID:     uint8(roomNum),
+					Username:   usernameRunes(userName),
+					SnakeShape: shape,
+					Settings:   settings,
+					Team:       team,
+					Color:      color,
+				}
+				response := session.Command(commandRequest)
 				if response.JoinRoom && response.IsSuccess {
+					stateMutex.Lock()
+					killFeed = nil
+					lastResponse = DisplayResponse{}
+					session.pendingMoves = nil
+					statusMessage = ""
+					resetTimeline()
+					stateMutex.Unlock()
+
+					if guestName != "" {
+						// The second player joins on a session of their own,
+						// in any team and colour
+						second := Connect()
+						commandRequest.Username = usernameRunes(guestName)
+						commandRequest.Team, commandRequest.Color = 0, 0
+						response := second.Command(commandRequest)
+						joined := response.JoinRoom && response.IsSuccess
+						stateMutex.Lock()
+						if joined {
+							guest = second
+						} else {
+							statusMessage = "The second player couldn't join, the room is full"
+						}
+						stateMutex.Unlock()
+						if joined {
+							go receiveGuest(second)
+						} else {
+							second.Close()
+						}
+					}
+
+					isPlayingMutex.Lock()
 					isPlaying = true
+					kicked = false
+					isPlayingMutex.Unlock()
 				} else {
 					clearScreen()
 					fmt.Println("Room is full")
@@ -214,99 +254,219 @@ func main() {
 	}
 }
 
-func closeConn(tcpSocket *net.TCPConn, udpSocket *net.UDPConn) {
-	udpSocket.Close()
-	for {
-		commandRequest := CommandRequest{userID, false, 0, false, true, [5]rune(make([]rune, 5)), 0}
-		encodedCommandRequest := encodeCommandRequest(commandRequest)
-		tcpSocket.Write(encodedCommandRequest)
+// Room settings are only used by the server when the room is new
+func readRoomSettings() RoomSettings {
+	settings := RoomSettings{}
 
-		receiveBuffer := make([]byte, BUFFER_SIZE)
-		receiveLength, _ := tcpSocket.Read(receiveBuffer)
-		commandResponse := decodeCommandResponse(receiveBuffer[:receiveLength])
+	settings.TickInterval = uint16(readNumber("Enter tick interval in ms for a new room (blank for 750): ", 65535))
+	settings.SpeedMode = uint8(readNumber("Enter speed mode for a new room (0 fixed, 1 score, 2 level, 3 length): ", 3))
 
-		if commandResponse.IsSuccess && commandResponse.Quit {
-			break
-		}
+	var sizeString string
+	fmt.Print("Enter map size for a new room as WIDTHxHEIGHT (blank for 30x30): ")
+	fmt.Scanln(&sizeString)
+	var width, height int
+	if _, err := fmt.Sscanf(sizeString, "%dx%d", &width, &height); err == nil && width > 0 && width < 256 && height > 0 && height < 256 {
+		settings.Width = uint8(width)
+		settings.Height = uint8(height)
+	}
+
+	var mapString string
+	fmt.Print("Enter map name for a new room (blank for an empty arena): ")
+	fmt.Scanln(&mapString)
+	copy(settings.Map[:], []rune(mapString))
+
+	var wrapString string
+	fmt.Print("Wrap around the edges of a new room? (y/N): ")
+	fmt.Scanln(&wrapString)
+	settings.Wrap = wrapString == "y" || wrapString == "Y"
+
+	settings.RespawnDelay = uint16(readNumber("Enter respawn delay in ms for a new room (blank for 2000): ", 65535))
+	settings.Protection = uint16(readNumber("Enter spawn protection in ms for a new room (blank for 2000): ", 65535))
+	settings.Lives = uint8(readNumber("Enter lives for a new room (blank for unlimited): ", 255))
+	settings.Teams = uint8(readNumber("Enter number of teams for a new room (blank for free-for-all): ", len(TEAM_NAMES)))
+	if settings.Teams > 0 {
+		var friendlyFireString string
+		fmt.Print("Can teammates kill each other? (y/N): ")
+		fmt.Scanln(&friendlyFireString)
+		settings.FriendlyFire = friendlyFireString == "y" || friendlyFireString == "Y"
+	}
+	settings.WinCondition = uint8(readNumber("Enter win condition for a new room (0 endless, 1 points, 2 timer, 3 last alive, 4 battle royale): ", 4))
+	switch settings.WinCondition {
+	case engine.WIN_POINTS:
+		settings.WinPoints = uint16(readNumber("Enter points needed to win (blank for 20): ", 65535))
+	case engine.WIN_TIMER:
+		settings.MatchTime = uint16(readNumber("Enter round length in seconds (blank for 120): ", 65535))
+	case engine.WIN_ROYALE:
+		settings.ShrinkTime = uint16(readNumber("Enter seconds between zone shrinks (blank for 10): ", 65535))
+	}
+
+	var weightsString string
+	fmt.Printf("Enter food weights for a new room as %s (blank for the defaults): ", strings.Join(FOOD_NAMES, ","))
+	fmt.Scanln(&weightsString)
+	if weights, err := parseFoodList(weightsString); err == nil {
+		settings.FoodWeights = weights
+	}
+	settings.FoodSpawner = uint8(readNumber("Enter food spawner for a new room (0 anywhere, 1 clustered, 2 far from snakes, 3 map spawn points, 4 dead snakes turn into food): ", 4))
+
+	return settings
+}
+
+// readNumber reads a number between 0 and max, anything else reads as 0
+func readNumber(prompt string, max int) int {
+	var numString string
+	fmt.Print(prompt)
+	fmt.Scanln(&numString)
+	num, err := strconv.Atoi(numString)
+	if err != nil || num < 0 || num > max {
+		return 0
 	}
-	tcpSocket.Close()
+	return num
 }
 
+// usernameRunes pads or cuts a username to the NAME_SIZE runes sent to the
+// server
+func usernameRunes(name string) [engine.NAME_SIZE]rune {
+	var username [engine.NAME_SIZE]rune
+	copy(username[:], []rune(name))
+	return username
+}
+
+// clearScreen clears the terminal outside of rooms, rooms are drawn by screen
 func clearScreen() {
-	var cmd *exec.Cmd
-	switch runtime.GOOS {
-	case "windows":
-		cmd = exec.Command("cmd", "/c", "cls")
-	default:
-		cmd = exec.Command("clear")
+	fmt.Print(ESC_CLEAR_SCREEN)
+}
+
+// render writes a frame of the room laid out for the size of the terminal,
+// with the status bar on its last row
+func render(w io.Writer, frame Frame) {
+	response := frame.Response
+	if response.Match.Phase == engine.MATCH_RESULTS {
+		drawResults(w, response.Match)
+		return
+	}
+
+	height := frame.Height
+	if frame.Stats != "" {
+		// The statistics take a row above the status bar
+		height--
 	}
-	cmd.Stdout = os.Stdout
-	cmd.Run()
+	layout, ok := NewLayout(frame.Width, height, response.Width, response.Height)
+	lines := tooSmall(frame.Width, height, response.Width, response.Height)
+	if frame.ShowBindings {
+		lines = bindingsScreen()
+	} else if ok {
+		view := drawMap(frame, layout)
+		sidebar := sidebarLines(frame)
+		switch layout.Sidebar {
+		case SIDEBAR_RIGHT:
+			// The sidebar starts on the second line and goes on below the
+			// map when it's longer
+			lines = []string{view.Row(0)}
+			gap := strings.Repeat(" ", SIDEBAR_GAP)
+			indent := strings.Repeat(" ", boardWidth(view.Width, view.CellWidth)) + gap
+			for i, line := range sidebar {
+				if i+1 < len(view.Grid) {
+					lines = append(lines, view.Row(i+1)+gap+line)
+				} else {
+					lines = append(lines, indent+line)
+				}
+			}
+			for i := len(lines); i < len(view.Grid); i++ {
+				lines = append(lines, view.Row(i))
+			}
+		case SIDEBAR_BELOW:
+			lines = []string{}
+			for i := range view.Grid {
+				lines = append(lines, view.Row(i))
+			}
+			lines = append(lines, sidebar...)
+		}
+	}
+
+	rows := max(height-1, 0)
+	if len(lines) > rows {
+		lines = lines[:rows]
+	}
+	for len(lines) < rows {
+		lines = append(lines, "")
+	}
+	if frame.Stats != "" {
+		lines = append(lines, frame.Stats)
+	}
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintln(w, bottomLine(response))
 }
 
-func draw(udpSocket *net.UDPConn) {
-	receiveBuffer := make([]byte, BUFFER_SIZE)
-	receiveLength, _, _ := udpSocket.ReadFromUDP(receiveBuffer)
-	clearScreen()
-	response := decodeDisplayResponse(receiveBuffer[:receiveLength])
-	// fmt.Println(string(receiveBuffer[:receiveLength]))
-	roomMap := [][]rune{
-		{'#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', '#'},
-		{'#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#', ' ', '#'},
+// drawMap draws the part of the map around our snake, coloured with the
+// theme of the frame
+func drawMap(frame Frame, layout Layout) *Viewport {
+	response, theme := frame.Response, frame.Theme
+	view := NewViewport(response.Width, response.Height, response.Wrap, cameraFocus(response.Players, frame.locals(), frame.Spectate), layout)
+	view.ColorBorder(theme.Wall.Fg())
+	if theme.Background != nil {
+		view.Background = theme.Background.Bg()
+	}
+
+	if response.Settings.WinCondition == engine.WIN_ROYALE {
+		// Cells outside of the zone are lethal
+		outside := []Location{}
+		for y := uint8(0); y < response.Height; y++ {
+			for x := uint8(0); x < response.Width; x++ {
+				if !inZone(response.Zone, Location{X: x, Y: y}) {
+					outside = append(outside, Location{X: x, Y: y})
+					view.Set(Location{X: x, Y: y}, '~')
+				}
+			}
+		}
+		view.SetColor(outside, theme.Zone.Fg())
+	}
+	for _, wall := range response.Walls {
+		view.Set(wall, '#')
+	}
+	view.SetColor(response.Walls, theme.Wall.Fg())
+
+	for _, food := range response.Foods {
+		view.Set(food.Location, foodGlyph(food.Kind))
+		view.SetColor([]Location{food.Location}, theme.Food.Fg())
 	}
+
 	for _, player := range response.Players {
-		head := player.Snake[0]
-		roomMap[head.Y+1][(head.X+1)*2] = player.Move
-		userName := []rune(player.Username)
+		if len(player.Snake) == 0 {
+			continue
+		}
+		color := snakeColor(player)
+		if frame.Guest != 0 && slices.Contains(frame.locals(), player.UserID) {
+			color = STYLE_BOLD + color
+		}
+		view.SetColor(player.Snake, color)
+		view.Set(player.Snake[0], player.Move)
+		userName := characters(player.Username)
 		// Add player to map
 		for i := 1; i < len(player.Snake); i++ {
 			loc := player.Snake[i]
+			if player.Protected > 0 {
+				// Bodies of protected snakes are drawn with '+'
+				view.Set(loc, '+')
+				continue
+			}
 			if i-2 < len(userName) && i > 1 {
-				roomMap[loc.Y+1][(loc.X+1)*2] = userName[i-2]
+				view.SetText(loc, userName[i-2])
 				continue
 			}
-			roomMap[loc.Y+1][(loc.X+1)*2] = player.SnakeShape
+			view.Set(loc, player.SnakeShape)
 		}
 	}
 
-	for _, food := range response.Foods {
-		roomMap[food.Y+1][(food.X+1)*2] = '$'
-	}
-
-	fmt.Println(string(roomMap[0]))
-	thirdLine := string(roomMap[1]) + "\tLeaderboard"
-	fmt.Println(thirdLine)
+	return view
+}
 
+// sidebarLines returns the text shown next to the map
+func sidebarLines(frame Frame) []string {
+	response, feed := frame.Response, frame.Feed
+	// The players may still be the ones of the newest snapshot
+	response.Players = slices.Clone(response.Players)
 	sort.SliceStable(response.Players, func(i int, j int) bool {
 		if response.Players[i].Point != response.Players[j].Point {
 			return response.Players[i].Point > response.Players[j].Point
@@ -315,18 +475,266 @@ func draw(udpSocket *net.UDPConn) {
 		return response.Players[i].Username < response.Players[j].Username
 	})
 
-	for i := 2; i < len(response.Players)+2; i++ {
-		player := response.Players[i-2]
-		lines := fmt.Sprintf("%s\t%s - %d - '%c'", string(roomMap[i]), string(player.Username), player.Point, player.SnakeShape)
-		fmt.Println(lines)
+	// Text shown on the right of the map, starting from the second line
+	sidebar := []string{}
+	if frame.ShowScoreboard {
+		sidebar = append(sidebar, "Leaderboard")
+	}
+	for _, player := range response.Players {
+		if !frame.ShowScoreboard {
+			break
+		}
+		line := fmt.Sprintf("%s - %d - '%c' - %d kills", string(player.Username), player.Point, player.SnakeShape, player.Kills)
+		if player.Lives > 0 {
+			line += fmt.Sprintf(" - %d lives", player.Lives)
+		}
+		if player.Spectator {
+			line += " (out)"
+		} else if len(player.Snake) == 0 {
+			line += " (dead)"
+		}
+		if player.UserID == response.HostID {
+			line += " [host]"
+		}
+		if player.Bot {
+			line += " [bot]"
+		}
+		if response.Match.Phase == engine.MATCH_LOBBY && player.Ready {
+			line += " (ready)"
+		}
+		if i := slices.Index(frame.locals(), player.UserID); i != -1 && frame.Guest != 0 {
+			line += fmt.Sprintf(" [P%d]", i+1)
+		}
+		if color := snakeColor(player); color != "" {
+			line = color + line + COLOR_RESET
+		}
+		sidebar = append(sidebar, line)
+	}
+	if len(response.TeamScores) > 0 {
+		sidebar = append(sidebar, "", "Teams")
+		sidebar = append(sidebar, teamScores(response)...)
+	}
+	sidebar = append(sidebar, "")
+	sidebar = append(sidebar, matchStatus(response)...)
+	if response.MapName != "" {
+		sidebar = append(sidebar, fmt.Sprintf("Map: %s", response.MapName))
+	}
+	sidebar = append(sidebar, fmt.Sprintf("Speed: %d ms/tick", response.Speed))
+	if response.Level > 0 {
+		sidebar = append(sidebar, fmt.Sprintf("Level: %d", response.Level))
+	}
+	for _, player := range response.Players {
+		if !slices.Contains(frame.locals(), player.UserID) {
+			continue
+		}
+		status := []string{}
+		if player.Speed != response.Speed {
+			status = append(status, fmt.Sprintf("Your speed: %d ms/move", player.Speed))
+		}
+		if player.Spectator {
+			status = append(status, "Out of lives, spectating")
+		} else if len(player.Snake) == 0 {
+			status = append(status, fmt.Sprintf("Respawning in %.1fs", float64(player.RespawnIn)/1000))
+		} else if player.Protected > 0 {
+			status = append(status, fmt.Sprintf("Protected for %.1fs", float64(player.Protected)/1000))
+		}
+		status = append(status, effectLines(player)...)
+		if frame.Guest != 0 {
+			// Tell the two players at the keyboard apart
+			for i := range status {
+				status[i] = player.Username + ": " + status[i]
+			}
+		}
+		sidebar = append(sidebar, status...)
+	}
+	sidebar = append(sidebar, "", foodLegend())
+
+	if len(feed) != 0 {
+		sidebar = append(sidebar, "", "Kill feed")
+		sidebar = append(sidebar, feed...)
+	}
+
+	return sidebar
+}
+
+// bottomLine returns the command being typed, the result of the last command
+// or the keys available in the current match phase
+func bottomLine(response DisplayResponse) string {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	if commandLine != nil {
+		return "/" + string(commandLine)
+	} else if statusMessage != "" {
+		return statusMessage
+	}
+
+	keys := []string{}
+	if response.Match.Phase == engine.MATCH_LOBBY {
+		keys = append(keys, keyBindings.Key(ACTION_READY)+": ready")
+		if response.HostID == userID {
+			keys = append(keys, keyBindings.Key(ACTION_START)+": start match")
+		}
+	} else {
+		keys = append(keys, moveKeys()+": move")
+	}
+	keys = append(keys,
+		keyBindings.Key(ACTION_COMMAND)+": command",
+		keyBindings.Key(ACTION_BINDINGS)+": keys",
+		keyBindings.Key(ACTION_QUIT)+": leave",
+	)
+	return strings.Join(keys, "  ")
+}
+
+// moveKeys returns the keys moving up, left, down and right, like wasd
+func moveKeys() string {
+	keys := []string{
+		keyBindings.Key(ACTION_UP),
+		keyBindings.Key(ACTION_LEFT),
+		keyBindings.Key(ACTION_DOWN),
+		keyBindings.Key(ACTION_RIGHT),
+	}
+	for _, key := range keys {
+		if utf8.RuneCountInString(key) != 1 {
+			return strings.Join(keys, "/")
+		}
+	}
+	return strings.Join(keys, "")
+}
+
+// matchStatus returns the sidebar lines describing the match
+func matchStatus(response DisplayResponse) []string {
+	match := response.Match
+	seconds := (match.TimeLeft + 999) / 1000
+	switch match.Phase {
+	case engine.MATCH_LOBBY:
+		ready := 0
+		for _, player := range response.Players {
+			if player.Ready {
+				ready++
+			}
+		}
+		return []string{fmt.Sprintf("Lobby - %d/%d ready", ready, len(response.Players))}
+	case engine.MATCH_COUNTDOWN:
+		return []string{fmt.Sprintf("Round %d starts in %d", match.Round+1, seconds)}
+	}
+
+	switch response.Settings.WinCondition {
+	case engine.WIN_POINTS:
+		return []string{fmt.Sprintf("Round %d - first to %d points", match.Round, response.Settings.WinPoints)}
+	case engine.WIN_TIMER:
+		return []string{fmt.Sprintf("Round %d - %d:%02d left", match.Round, seconds/60, seconds%60)}
+	case engine.WIN_LAST_ALIVE:
+		return []string{fmt.Sprintf("Round %d - last snake alive wins", match.Round)}
+	case engine.WIN_ROYALE:
+		lines := []string{fmt.Sprintf("Round %d - battle royale", match.Round)}
+		zone := fmt.Sprintf("Zone: %dx%d", response.Zone.Width, response.Zone.Height)
+		if response.ShrinkIn > 0 {
+			zone += fmt.Sprintf(", shrinks in %d", (response.ShrinkIn+999)/1000)
+		}
+		return append(lines, zone)
+	}
+	return nil
+}
+
+// drawResults shows the results of a round in place of the map
+func drawResults(w io.Writer, match Match) {
+	fmt.Fprintf(w, "Round %d is over\n\n", match.Round)
+
+	winners := []string{}
+	for _, result := range match.Results {
+		if !result.Winner {
+			continue
+		}
+		winner := result.Username
+		if result.Team > 0 {
+			winner = teamName(result.Team) + " team"
+		}
+		if !slices.Contains(winners, winner) {
+			winners = append(winners, winner)
+		}
+	}
+	if len(winners) == 0 {
+		fmt.Fprintln(w, "Nobody won, it's a draw")
+	} else {
+		fmt.Fprintf(w, "Winner: %s\n", strings.Join(winners, ", "))
 	}
 
-	for i := len(response.Players) + 2; i < len(roomMap); i++ {
-		fmt.Println(string(roomMap[i]))
+	fmt.Fprintf(w, "\n%-4s %-5s %6s %6s %6s\n", "#", "Name", "Points", "Kills", "Deaths")
+	for i, result := range match.Results {
+		line := fmt.Sprintf("%-4d %s %6d %6d %6d", i+1, padText(result.Username, 5), result.Point, result.Kills, result.Deaths)
+		if color := teamColor(result.Team); color != "" {
+			line = color + line + COLOR_RESET
+		}
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintf(w, "\nNext round in %d\n", (match.TimeLeft+999)/1000)
+}
+
+// cameraFocus returns the head of the snake of a player at this keyboard,
+// the first one alive. While they are dead or spectating it's the head of
+// the snake we picked to spectate, or of the best living snake.
+func cameraFocus(players []Player, locals []uint32, spectate uint32) Location {
+	for _, id := range locals {
+		for _, player := range players {
+			if player.UserID == id && len(player.Snake) != 0 {
+				return player.Snake[0]
+			}
+		}
+	}
+	var best, spectated *Player
+	for i, player := range players {
+		if len(player.Snake) == 0 {
+			continue
+		}
+		if player.UserID == spectate {
+			spectated = &players[i]
+		}
+		if best == nil || player.Point > best.Point {
+			best = &players[i]
+		}
+	}
+	if spectated != nil {
+		return spectated.Snake[0]
+	}
+	if best == nil {
+		return Location{}
 	}
+	return best.Snake[0]
 }
 
-func readKeyboard(tcpSocket *net.TCPConn, udpSocket *net.UDPConn) {
+// describeEvent returns the kill feed line of an event, food events aren't
+// shown on the kill feed
+func describeEvent(event Event) string {
+	switch event.Type {
+	case engine.EVENT_JOIN:
+		return fmt.Sprintf("%s joined", event.Username)
+	case engine.EVENT_LEAVE:
+		return fmt.Sprintf("%s left", event.Username)
+	case engine.EVENT_DEATH:
+		switch event.Cause {
+		case engine.DEATH_WALL:
+			return fmt.Sprintf("%s hit a wall", event.Username)
+		case engine.DEATH_SELF:
+			return fmt.Sprintf("%s bit itself", event.Username)
+		case engine.DEATH_SNAKE:
+			return fmt.Sprintf("%s was killed by %s", event.Username, event.Killer)
+		case engine.DEATH_HEAD_ON:
+			return fmt.Sprintf("%s crashed head-on into %s", event.Username, event.Killer)
+		case engine.DEATH_ZONE:
+			return fmt.Sprintf("%s was caught outside the zone", event.Username)
+		}
+	case engine.EVENT_OUT:
+		return fmt.Sprintf("%s is out of lives", event.Username)
+	case engine.EVENT_HOST:
+		return fmt.Sprintf("%s is now the host", event.Username)
+	case engine.EVENT_CHAT:
+		return fmt.Sprintf("%s: %s", event.Username, event.Text)
+	}
+	return ""
+}
+
+func readKeyboard() {
 	if err := keyboard.Open(); err != nil {
 		log.Fatalln(err)
 	}
@@ -338,63 +746,133 @@ func readKeyboard(tcpSocket *net.TCPConn, udpSocket *net.UDPConn) {
 			log.Fatalln(err)
 		}
 
-		if key == keyboard.KeyEsc {
-			isPlayingMutex.Lock()
+		isPlayingMutex.Lock()
+		kicked := !isPlaying
+		isPlayingMutex.Unlock()
+		if kicked {
+			break
+		}
 
-			encodedCommand := encodeCommandRequest(CommandRequest{userID, false, 0, true, false, [5]rune(make([]rune, 5)), 0})
-			tcpSocket.Write(encodedCommand)
+		stateMutex.Lock()
+		isTyping := commandLine != nil
+		second := guest
+		stateMutex.Unlock()
+		if isTyping {
+			readCommandKey(char, key)
+			continue
+		}
 
-			receiveBuffer := make([]byte, BUFFER_SIZE)
-			receiveLength, _ := tcpSocket.Read(receiveBuffer)
-			response := decodeCommandResponse(receiveBuffer[:receiveLength])
+		action := keyBindings.Action(keyName(char, key), second != nil)
+		switch action {
+		case ACTION_QUIT:
+			isPlayingMutex.Lock()
 
+			response := session.Command(CommandRequest{ExitRoom: true})
 			if response.ExitRoom && response.IsSuccess {
 				isPlaying = false
 			}
 
 			isPlayingMutex.Unlock()
-			break
-		} else if char == 'w' {
-			moveRequest := MoveRequest{userID, '^'}
-			encodedMoveRequest := encodeMoveRequest(moveRequest)
-			udpSocket.Write(encodedMoveRequest)
-		} else if char == 's' {
-			moveRequest := MoveRequest{userID, 'v'}
-			encodedMoveRequest := encodeMoveRequest(moveRequest)
-			udpSocket.Write(encodedMoveRequest)
-		} else if char == 'd' {
-			moveRequest := MoveRequest{userID, '>'}
-			encodedMoveRequest := encodeMoveRequest(moveRequest)
-			udpSocket.Write(encodedMoveRequest)
-		} else if char == 'a' {
-			moveRequest := MoveRequest{userID, '<'}
-			encodedMoveRequest := encodeMoveRequest(moveRequest)
-			udpSocket.Write(encodedMoveRequest)
+			return
+		case ACTION_COMMAND, ACTION_CHAT:
+			stateMutex.Lock()
+			commandLine = []rune{}
+			if action == ACTION_CHAT {
+				commandLine = []rune("say ")
+			}
+			statusMessage = ""
+			stateMutex.Unlock()
+		case ACTION_READY:
+			// Everyone at the keyboard is ready at once
+			stateMutex.Lock()
+			locals := localSessions()
+			ready := make([]bool, len(locals))
+			for i, local := range locals {
+				for _, player := range lastResponse.Players {
+					if player.UserID == local.UserID {
+						ready[i] = player.Ready
+					}
+				}
+			}
+			stateMutex.Unlock()
+			for i, local := range locals {
+				local.Command(CommandRequest{Ready: !ready[i], Unready: ready[i]})
+			}
+		case ACTION_START:
+			session.Command(CommandRequest{StartMatch: true})
+		case ACTION_SCOREBOARD:
+			stateMutex.Lock()
+			showScoreboard = !showScoreboard
+			stateMutex.Unlock()
+		case ACTION_BINDINGS:
+			stateMutex.Lock()
+			showBindings = !showBindings
+			stateMutex.Unlock()
+		case ACTION_STATS:
+			stateMutex.Lock()
+			showStats = !showStats
+			stateMutex.Unlock()
+		case ACTION_SPECTATE:
+			spectateNext()
+		case ACTION_UP, ACTION_DOWN, ACTION_LEFT, ACTION_RIGHT:
+			sendMove(session, ACTION_MOVES[action])
+		case ACTION_GUEST_UP, ACTION_GUEST_DOWN, ACTION_GUEST_LEFT, ACTION_GUEST_RIGHT:
+			sendMove(second, ACTION_MOVES[action])
 		}
 	}
 }
 
-func encodeCommandRequest(request CommandRequest) []byte {
+// readCommandKey edits the command line, Enter runs it and Esc cancels it
+func readCommandKey(char rune, key keyboard.Key) {
+	stateMutex.Lock()
+	line := string(commandLine)
+	switch key {
+	case keyboard.KeyEsc:
+		commandLine = nil
+	case keyboard.KeyEnter:
+		commandLine = nil
+	case keyboard.KeyBackspace, keyboard.KeyBackspace2:
+		if len(commandLine) > 0 {
+			commandLine = commandLine[:len(commandLine)-1]
+		}
+	case keyboard.KeySpace:
+		commandLine = append(commandLine, ' ')
+	default:
+		if char != 0 {
+			commandLine = append(commandLine, char)
+		}
+	}
+	stateMutex.Unlock()
+
+	if key == keyboard.KeyEnter {
+		message := runCommand(line)
+		stateMutex.Lock()
+		statusMessage = message
+		stateMutex.Unlock()
+	}
+}
+
+func encodeCommandRequest(request CommandRequest, key []byte) []byte {
 	bytesBuffer := new(bytes.Buffer)
 	err := binary.Write(bytesBuffer, binary.BigEndian, request)
 	if err != nil {
 		log.Fatalln(err)
 	}
-	return encryptMessage(bytesBuffer.Bytes())
+	return encryptMessage(bytesBuffer.Bytes(), key)
 }
 
-func encodeMoveRequest(request MoveRequest) []byte {
+func encodeMoveRequest(request MoveRequest, key []byte) []byte {
 	bytesBuffer := new(bytes.Buffer)
 	err := binary.Write(bytesBuffer, binary.BigEndian, request)
 	if err != nil {
 		log.Fatalln(err)
 	}
-	return encryptMessage(bytesBuffer.Bytes())
+	return encryptMessage(bytesBuffer.Bytes(), key)
 }
 
-func decodeCommandResponse(bytesResponse []byte) CommandResponse {
+func decodeCommandResponse(bytesResponse []byte, key []byte) CommandResponse {
 	var response CommandResponse
-	bytesReader := bytes.NewReader(decryptMessage(bytesResponse))
+	bytesReader := bytes.NewReader(decryptMessage(bytesResponse, key))
 	err := binary.Read(bytesReader, binary.BigEndian, &response)
 	if err != nil {
 		log.Fatalln(err)
@@ -408,8 +886,8 @@ func decodeDisplayResponse(bytesResponse []byte) DisplayResponse {
 	return response
 }
 
-func encryptMessage(message []byte) []byte {
-	block, err := aes.NewCipher(symmetricKey)
+func encryptMessage(message []byte, key []byte) []byte {
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -427,8 +905,8 @@ func encryptMessage(message []byte) []byte {
 	return encrypted
 }
 
-func decryptMessage(message []byte) []byte {
-	block, err := aes.NewCipher(symmetricKey)
+func decryptMessage(message []byte, key []byte) []byte {
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		log.Fatalln(err)
 	}
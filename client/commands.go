@@ -0,0 +1,182 @@
+package main
+
+import (
+	"engine"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Help shown for the command line opened with '/'
+const COMMAND_HELP = "/ready /unready /start /team NUMBER /kick NAME /host NAME /set KEY VALUE /say MESSAGE /theme NAME"
+
+// Settings /set can change
+const SETTINGS_HELP = "tick speed size map wrap respawn protection lives teams friendlyfire win points time shrink weights caps spawner"
+
+var (
+	lastResponse  DisplayResponse // Latest snapshot, shared with readKeyboard
+	commandLine   []rune          // Command being typed, nil when not typing
+	statusMessage string
+	stateMutex    sync.Mutex // Mutex for lastResponse, commandLine and statusMessage
+)
+
+// runCommand runs a line typed on the command line and returns the message
+// shown to the player
+func runCommand(line string) string {
+	stateMutex.Lock()
+	response := lastResponse
+	stateMutex.Unlock()
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+
+	request := CommandRequest{}
+	switch fields[0] {
+	case "ready":
+		request.Ready = true
+	case "unready":
+		request.Unready = true
+	case "start":
+		request.StartMatch = true
+	case "team":
+		team := 0
+		if len(fields) == 2 {
+			team, _ = strconv.Atoi(fields[1])
+		}
+		if team <= 0 || team > int(response.Settings.Teams) {
+			return fmt.Sprintf("Usage: /team NUMBER, the room has %d teams", response.Settings.Teams)
+		}
+		request.ChangeTeam = true
+		request.Team = uint8(team)
+	case "say":
+		message := []rune(norm.NFC.String(strings.TrimSpace(strings.TrimPrefix(line, "say"))))
+		if len(message) == 0 || len(message) > engine.CHAT_SIZE {
+			return fmt.Sprintf("Usage: /say MESSAGE, %d characters at most", engine.CHAT_SIZE)
+		}
+		request.Chat = true
+		copy(request.Message[:], message)
+	case "theme":
+		if len(fields) != 2 || findTheme(fields[1]).Name != fields[1] {
+			return "Themes: " + themeNames()
+		}
+		stateMutex.Lock()
+		theme = findTheme(fields[1])
+		stateMutex.Unlock()
+		return "Theme changed to " + fields[1]
+	case "kick", "host":
+		if len(fields) != 2 {
+			return fmt.Sprintf("Usage: /%s NAME", fields[0])
+		}
+		target, found := findPlayer(response.Players, fields[1])
+		if !found {
+			return fmt.Sprintf("No player named %s", fields[1])
+		}
+		request.Kick = fields[0] == "kick"
+		request.TransferHost = fields[0] == "host"
+		request.TargetID = target.UserID
+	case "set":
+		if len(fields) != 3 {
+			return "Usage: /set KEY VALUE, keys: " + SETTINGS_HELP
+		}
+		// Settings come with the layout of the room, which may be late
+		if response.Settings == (RoomSettings{}) {
+			return "Room settings not received yet, try again"
+		}
+		settings, err := changeSetting(response.Settings, fields[1], fields[2])
+		if err != nil {
+			return err.Error()
+		}
+		request.ChangeSettings = true
+		request.Settings = settings
+	default:
+		return COMMAND_HELP
+	}
+
+	if !session.Command(request).IsSuccess {
+		return fmt.Sprintf("/%s failed", fields[0])
+	} else if request.Chat {
+		return ""
+	}
+	return fmt.Sprintf("/%s done", fields[0])
+}
+
+func findPlayer(players []Player, username string) (Player, bool) {
+	for _, player := range players {
+		if player.Username == norm.NFC.String(username) {
+			return player, true
+		}
+	}
+	return Player{}, false
+}
+
+// changeSetting returns settings with a single setting changed by /set
+func changeSetting(settings RoomSettings, key string, value string) (RoomSettings, error) {
+	if key == "map" {
+		settings.Map = [12]rune{}
+		if value != "none" {
+			copy(settings.Map[:], []rune(value))
+		}
+		return settings, nil
+	} else if key == "wrap" {
+		settings.Wrap = value == "on"
+		return settings, nil
+	} else if key == "weights" || key == "caps" {
+		list, err := parseFoodList(value)
+		if err != nil {
+			return settings, err
+		}
+		if key == "weights" {
+			settings.FoodWeights = list
+		} else {
+			settings.FoodCaps = list
+		}
+		return settings, nil
+	} else if key == "friendlyfire" {
+		settings.FriendlyFire = value == "on"
+		return settings, nil
+	} else if key == "size" {
+		var width, height int
+		if _, err := fmt.Sscanf(value, "%dx%d", &width, &height); err != nil || width <= 0 || width > 255 || height <= 0 || height > 255 {
+			return settings, fmt.Errorf("size must be WIDTHxHEIGHT")
+		}
+		settings.Width, settings.Height = uint8(width), uint8(height)
+		return settings, nil
+	}
+
+	num, err := strconv.Atoi(value)
+	if err != nil || num < 0 || num > 65535 {
+		return settings, fmt.Errorf("%s must be a number", key)
+	}
+	switch key {
+	case "tick":
+		settings.TickInterval = uint16(num)
+	case "speed":
+		settings.SpeedMode = uint8(num)
+	case "respawn":
+		settings.RespawnDelay = uint16(num)
+	case "protection":
+		settings.Protection = uint16(num)
+	case "lives":
+		settings.Lives = uint8(min(num, 255))
+	case "teams":
+		settings.Teams = uint8(min(num, 255))
+	case "win":
+		settings.WinCondition = uint8(min(num, 255))
+	case "points":
+		settings.WinPoints = uint16(num)
+	case "time":
+		settings.MatchTime = uint16(num)
+	case "spawner":
+		settings.FoodSpawner = uint8(min(num, 255))
+	case "shrink":
+		settings.ShrinkTime = uint16(num)
+	default:
+		return settings, fmt.Errorf("unknown setting %s, keys: %s", key, SETTINGS_HELP)
+	}
+	return settings, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"engine"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	FOOD_NAMES  = []string{"food", "bonus", "poison", "speed", "ghost", "magnet"}
+	FOOD_GLYPHS = []rune{'$', '*', '!', '&', '?', '@'}
+)
+
+func foodGlyph(kind uint8) rune {
+	if int(kind) >= len(FOOD_GLYPHS) {
+		return '$'
+	}
+	return FOOD_GLYPHS[kind]
+}
+
+// foodLegend returns the sidebar line explaining the food glyphs
+func foodLegend() string {
+	items := []string{}
+	for kind, name := range FOOD_NAMES {
+		items = append(items, fmt.Sprintf("%c %s", FOOD_GLYPHS[kind], name))
+	}
+	return strings.Join(items, "  ")
+}
+
+// effectLines returns a sidebar line for every food effect the player has
+func effectLines(player Player) []string {
+	lines := []string{}
+	if player.Boost > 0 {
+		lines = append(lines, fmt.Sprintf("Speed boost for %.1fs", float64(player.Boost)/1000))
+	}
+	if player.Ghost > 0 {
+		lines = append(lines, fmt.Sprintf("Ghost for %.1fs", float64(player.Ghost)/1000))
+	}
+	if player.Magnet > 0 {
+		lines = append(lines, fmt.Sprintf("Magnet for %.1fs", float64(player.Magnet)/1000))
+	}
+	return lines
+}
+
+// parseFoodList parses a number for every food kind separated by commas, as
+// used for food weights and caps
+func parseFoodList(value string) ([engine.FOOD_KINDS]uint8, error) {
+	list := [engine.FOOD_KINDS]uint8{}
+	fields := strings.Split(value, ",")
+	if len(fields) != int(engine.FOOD_KINDS) {
+		return list, fmt.Errorf("expected %d numbers for %s", engine.FOOD_KINDS, strings.Join(FOOD_NAMES, ","))
+	}
+	for i, field := range fields {
+		num, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || num < 0 || num > 255 {
+			return list, fmt.Errorf("%s is not a number between 0 and 255", field)
+		}
+		list[i] = uint8(num)
+	}
+	return list, nil
+}
@@ -3,6 +3,11 @@ module client
 go 1.23.0
 
 require (
-	github.com/eiannone/keyboard v0.0.0-20220611211555-0d226195f203 // indirect
-	golang.org/x/sys v0.0.0-20220520151302-bc2c85ada10a // indirect
+	engine v0.0.0
+	github.com/eiannone/keyboard v0.0.0-20220611211555-0d226195f203
+	github.com/rivo/uniseg v0.4.7
+	golang.org/x/sys v0.0.0-20220520151302-bc2c85ada10a
+	golang.org/x/text v0.28.0
 )
+
+replace engine => ../engine
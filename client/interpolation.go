@@ -0,0 +1,269 @@
+package main
+
+import (
+	"cmp"
+	"engine"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	FRAME_RATE           = 20 // Frames drawn per second
+	SNAPSHOT_BUFFER_SIZE = 16
+	// Extra delay of the timeline so snapshots slowed down on the way are
+	// still shown on time
+	JITTER_DELAY     = 100 * time.Millisecond
+	MAX_RENDER_DELAY = time.Second
+	// A room clock late on ours is followed by this fraction of its lateness
+	// on every snapshot
+	CLOCK_DRIFT_RATE = 16
+)
+
+var (
+	snapshots []DisplayResponse // Buffered snapshots, oldest first, guarded by stateMutex
+	roomStart time.Time         // Our time when the room clock was 0, guarded by stateMutex
+	layout    engine.RoomLayout // Newest layout of the room, guarded by stateMutex
+)
+
+// receiveSnapshots buffers the snapshots of the room until the session is
+// closed, so frames are drawn at their own rate whatever the network does
+func receiveSnapshots(session *Session) {
+	receiveBuffer := make([]byte, UDP_BUFFER_SIZE)
+	for {
+		snapshot, ok := session.Receive(receiveBuffer)
+		if !ok {
+			return
+		}
+		arrived := time.Now()
+		response := decodeDisplayResponse(snapshot)
+		if response.Pong {
+			stateMutex.Lock()
+			netStats.Pong(response.PingSent, arrived)
+			stateMutex.Unlock()
+			continue
+		}
+
+		isPlayingMutex.Lock()
+		playing := isPlaying
+		if playing && response.Kicked {
+			isPlaying = false
+			kicked = true
+		}
+		isPlayingMutex.Unlock()
+		if !playing || response.Kicked {
+			continue
+		}
+
+		stateMutex.Lock()
+		netStats.Snapshot(response.Snapshot, arrived)
+		bufferSnapshot(withLayout(response), arrived)
+		stateMutex.Unlock()
+	}
+}
+
+// receiveGuest reads the snapshots of the second player. They show the room
+// receiveSnapshots already draws, only being kicked matters.
+func receiveGuest(second *Session) {
+	receiveBuffer := make([]byte, UDP_BUFFER_SIZE)
+	for {
+		snapshot, ok := second.Receive(receiveBuffer)
+		if !ok {
+			return
+		}
+		if !decodeDisplayResponse(snapshot).Kicked {
+			continue
+		}
+		stateMutex.Lock()
+		kicked := guest == second
+		if kicked {
+			guest = nil
+			statusMessage = "The second player was kicked from the room"
+		}
+		stateMutex.Unlock()
+		if kicked {
+			second.Close()
+		}
+		return
+	}
+}
+
+// resetTimeline forgets the snapshots of the previous room
+func resetTimeline() {
+	snapshots = nil
+	roomStart = time.Time{}
+	layout = engine.RoomLayout{}
+	netStats = NetStats{}
+}
+
+// withLayout fills in a snapshot from the layout of the room, keeping the
+// layout when the snapshot carries a newer one
+func withLayout(response DisplayResponse) DisplayResponse {
+	if response.Layout != nil && response.Layout.Version >= layout.Version {
+		layout = *response.Layout
+	}
+	response.UseLayout(layout)
+	return response
+}
+
+// bufferSnapshot adds a snapshot to the timeline. The newest snapshot is also
+// the one prediction and commands work from.
+func bufferSnapshot(response DisplayResponse, arrived time.Time) {
+	i, found := slices.BinarySearchFunc(snapshots, response.Tick, func(snapshot DisplayResponse, tick uint32) int {
+		return cmp.Compare(snapshot.Tick, tick)
+	})
+	if found || (i == 0 && len(snapshots) == SNAPSHOT_BUFFER_SIZE) {
+		// Snapshots may arrive twice or too late to be shown
+		return
+	}
+	newest := i == len(snapshots)
+	snapshots = slices.Insert(snapshots, i, response)
+	if len(snapshots) > SNAPSHOT_BUFFER_SIZE {
+		snapshots = slices.Delete(snapshots, 0, 1)
+	}
+
+	// The snapshots that took the least time to arrive tell best when the
+	// room clock started, the others were slowed down on the way
+	start := arrived.Add(-time.Duration(response.Time) * time.Millisecond)
+	if roomStart.IsZero() || start.Before(roomStart) {
+		roomStart = start
+	} else {
+		// Ticks of a busy server take longer than planned
+		roomStart = roomStart.Add(start.Sub(roomStart) / CLOCK_DRIFT_RATE)
+	}
+
+	if !newest {
+		return
+	}
+	lastResponse = response
+	reconcile(response)
+	if response.Match.Phase != engine.MATCH_RESULTS {
+		for _, event := range response.Events {
+			if line := describeEvent(event); line != "" {
+				killFeed = append(killFeed, line)
+			}
+		}
+		if len(killFeed) > KILL_FEED_SIZE {
+			killFeed = killFeed[len(killFeed)-KILL_FEED_SIZE:]
+		}
+	}
+}
+
+// renderDelay returns how far behind the room clock the timeline is shown.
+// The next snapshot must have arrived by then to move towards it, so the
+// delay is the shortest time between two snapshots plus some jitter.
+func renderDelay() time.Duration {
+	if len(snapshots) < 2 {
+		return JITTER_DELAY
+	}
+	interval := MAX_RENDER_DELAY
+	for i := 1; i < len(snapshots); i++ {
+		interval = min(interval, roomTime(snapshots[i].Time-snapshots[i-1].Time))
+	}
+	return min(interval+JITTER_DELAY, MAX_RENDER_DELAY)
+}
+
+func roomTime(milliseconds uint32) time.Duration {
+	return time.Duration(milliseconds) * time.Millisecond
+}
+
+// frameAt returns what to draw at a given time. Other snakes come from the
+// timeline while the local ones are predicted from the newest snapshot, and everything
+// else shown on the sidebar comes from the newest snapshot.
+func frameAt(now time.Time) DisplayResponse {
+	frame := predict(lastResponse)
+	if len(snapshots) == 0 || frame.Match.Phase == engine.MATCH_RESULTS {
+		return frame
+	}
+
+	// Find the two snapshots around the render time, the closest one is held
+	// when there is none on one side
+	renderTime := now.Sub(roomStart) - renderDelay()
+	from, to := snapshots[0], snapshots[0]
+	for _, snapshot := range snapshots {
+		to = snapshot
+		if roomTime(snapshot.Time) > renderTime {
+			break
+		}
+		from = snapshot
+	}
+	if from.Match.Phase != frame.Match.Phase || from.Match.Round != frame.Match.Round {
+		// The map is reset between rounds
+		return frame
+	}
+
+	players := slices.Clone(from.Players)
+	if to.Time > from.Time {
+		progress := float64(renderTime-roomTime(from.Time)) / float64(roomTime(to.Time-from.Time))
+		players = interpolate(players, to.Players, progress)
+	}
+	for _, local := range localSessions() {
+		if own, alive := ownSnake(frame, local.UserID); alive {
+			i := slices.IndexFunc(players, func(player Player) bool { return player.UserID == local.UserID })
+			if i == -1 {
+				players = append(players, own)
+			} else {
+				players[i] = own
+			}
+		}
+	}
+	frame.Players = players
+	frame.Foods = from.Foods
+	frame.Zone = from.Zone
+	return frame
+}
+
+// interpolate moves the snakes towards the next snapshot. Halfway there the
+// heads enter their next cell, and the tails leave theirs on the next
+// snapshot. Snakes that moved more than a cell, because a snapshot was lost
+// or they respawned, jump on the next snapshot instead.
+func interpolate(players []Player, next []Player, progress float64) []Player {
+	if progress < 0.5 {
+		return players
+	}
+	heads := make(map[uint32]Player)
+	for _, player := range next {
+		heads[player.UserID] = player
+	}
+	for i, player := range players {
+		nextPlayer, exist := heads[player.UserID]
+		if !exist || len(player.Snake) == 0 || len(nextPlayer.Snake) < 2 || nextPlayer.Snake[1] != player.Snake[0] {
+			continue
+		}
+		players[i].Snake = append([]Location{nextPlayer.Snake[0]}, player.Snake...)
+		players[i].Move = nextPlayer.Move
+	}
+	return players
+}
+
+// drawFrame draws the timeline at the current time
+func drawFrame() {
+	stateMutex.Lock()
+	response := frameAt(time.Now())
+	frame := Frame{
+		Response:       response,
+		Feed:           slices.Clone(killFeed),
+		Theme:          theme,
+		ShowScoreboard: showScoreboard,
+		ShowBindings:   showBindings,
+		Spectate:       spectateID,
+	}
+	if showStats {
+		frame.Stats = netStats.Line(response, time.Now())
+	}
+	if guest != nil {
+		frame.Guest = guest.UserID
+	}
+	stateMutex.Unlock()
+
+	width, height, ok := terminalSize()
+	if !ok {
+		width, height = DEFAULT_COLUMNS, DEFAULT_ROWS
+	}
+	frame.Width, frame.Height = width, height
+	screen.Resize(width, height)
+
+	var out strings.Builder
+	render(&out, frame)
+	screen.Draw(out.String())
+}
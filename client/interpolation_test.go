@@ -0,0 +1,135 @@
+package main
+
+import (
+	"engine"
+	"slices"
+	"testing"
+	"time"
+)
+
+// ticks returns the ticks of the buffered snapshots
+func ticks() []uint32 {
+	ticks := []uint32{}
+	for _, snapshot := range snapshots {
+		ticks = append(ticks, snapshot.Tick)
+	}
+	return ticks
+}
+
+func TestBufferSnapshot(t *testing.T) {
+	localSession(t, 1)
+	resetTimeline()
+	t.Cleanup(func() {
+		resetTimeline()
+		lastResponse, killFeed = DisplayResponse{}, nil
+	})
+	start := time.Now()
+	snapshot := func(tick uint32) DisplayResponse {
+		return DisplayResponse{Tick: tick, Time: tick * 100, Match: Match{Phase: engine.MATCH_PLAYING}}
+	}
+
+	bufferSnapshot(snapshot(1), start.Add(150*time.Millisecond))
+	bufferSnapshot(snapshot(3), start.Add(320*time.Millisecond))
+	// The fastest snapshot tells when the room started
+	if want := start.Add(20 * time.Millisecond); !roomStart.Equal(want) {
+		t.Errorf("room started %v after start, want 20ms", roomStart.Sub(start))
+	}
+	// Late and twice arrived snapshots only fill the timeline
+	bufferSnapshot(snapshot(2), start.Add(400*time.Millisecond))
+	bufferSnapshot(snapshot(3), start.Add(400*time.Millisecond))
+	if got := ticks(); !slices.Equal(got, []uint32{1, 2, 3}) || lastResponse.Tick != 3 {
+		t.Errorf("timeline %v with newest tick %d, want [1 2 3] and 3", got, lastResponse.Tick)
+	}
+	// Slower ones only move it a little
+	if want := start.Add(20*time.Millisecond + 180*time.Millisecond/CLOCK_DRIFT_RATE); !roomStart.Equal(want) {
+		t.Errorf("room started %v after start, want %v", roomStart.Sub(start), want.Sub(start))
+	}
+
+	for tick := uint32(4); tick <= SNAPSHOT_BUFFER_SIZE+2; tick++ {
+		bufferSnapshot(snapshot(tick), start.Add(time.Duration(tick)*100*time.Millisecond))
+	}
+	bufferSnapshot(snapshot(2), start)
+	got := ticks()
+	if len(got) != SNAPSHOT_BUFFER_SIZE || got[0] != 3 || got[len(got)-1] != SNAPSHOT_BUFFER_SIZE+2 {
+		t.Errorf("full timeline %v, want ticks 3 to %d", got, SNAPSHOT_BUFFER_SIZE+2)
+	}
+
+	event := DisplayResponse{Tick: 30, Time: 3000, Events: []Event{{Type: engine.EVENT_JOIN, Username: "bob"}}}
+	bufferSnapshot(event, start.Add(3*time.Second))
+	bufferSnapshot(event, start.Add(3*time.Second))
+	if len(killFeed) != 1 {
+		t.Errorf("kill feed %q, want the join once", killFeed)
+	}
+}
+
+func TestRenderDelay(t *testing.T) {
+	t.Cleanup(resetTimeline)
+	tests := []struct {
+		times []uint32
+		want  time.Duration
+	}{
+		{nil, JITTER_DELAY},
+		{[]uint32{100}, JITTER_DELAY},
+		{[]uint32{0, 100, 250, 350}, 100*time.Millisecond + JITTER_DELAY},
+		{[]uint32{0, 5000}, MAX_RENDER_DELAY},
+	}
+	for _, test := range tests {
+		snapshots = nil
+		for _, time := range test.times {
+			snapshots = append(snapshots, DisplayResponse{Time: time})
+		}
+		if got := renderDelay(); got != test.want {
+			t.Errorf("renderDelay() of snapshots at %v = %v, want %v", test.times, got, test.want)
+		}
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	snake := []Location{{X: 5, Y: 5}, {X: 4, Y: 5}}
+	tests := []struct {
+		name     string
+		next     []Location
+		progress float64
+		want     []Location
+	}{
+		{"before_half", []Location{{X: 6, Y: 5}, {X: 5, Y: 5}}, 0.4, snake},
+		{"half", []Location{{X: 6, Y: 5}, {X: 5, Y: 5}}, 0.5, []Location{{X: 6, Y: 5}, {X: 5, Y: 5}, {X: 4, Y: 5}}},
+		{"grown", []Location{{X: 6, Y: 5}, {X: 5, Y: 5}, {X: 4, Y: 5}}, 0.9, []Location{{X: 6, Y: 5}, {X: 5, Y: 5}, {X: 4, Y: 5}}},
+		{"jump", []Location{{X: 7, Y: 5}, {X: 6, Y: 5}}, 0.9, snake},
+		{"dead", nil, 0.9, snake},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			players := []Player{{UserID: 1, Move: '>', Snake: slices.Clone(snake)}}
+			next := []Player{{UserID: 1, Move: '^', Snake: test.next}}
+			got := interpolate(players, next, test.progress)
+			if !slices.Equal(got[0].Snake, test.want) {
+				t.Errorf("interpolated snake %v, want %v", got[0].Snake, test.want)
+			}
+		})
+	}
+}
+
+func TestWithLayout(t *testing.T) {
+	t.Cleanup(resetTimeline)
+	resetTimeline()
+	walls := []Location{{X: 1, Y: 0}, {X: 2, Y: 3}}
+	first := &engine.RoomLayout{Version: 1, MapName: "first", Walls: engine.EncodeWalls(walls, 10)}
+	second := &engine.RoomLayout{Version: 2, MapName: "second"}
+
+	if got := withLayout(DisplayResponse{Width: 10, LayoutVersion: 1, Layout: first}); got.MapName != "first" || !slices.Equal(got.Walls, walls) {
+		t.Errorf("snapshot with the layout has map %q and walls %v", got.MapName, got.Walls)
+	}
+	if got := withLayout(DisplayResponse{Width: 10, LayoutVersion: 1}); got.MapName != "first" || !slices.Equal(got.Walls, walls) {
+		t.Errorf("snapshot without the layout has map %q and walls %v", got.MapName, got.Walls)
+	}
+	// Until the new layout arrives, snapshots of it have none
+	if got := withLayout(DisplayResponse{Width: 10, LayoutVersion: 2}); got.MapName != "" || got.Walls != nil {
+		t.Errorf("snapshot of a lost layout has map %q and walls %v", got.MapName, got.Walls)
+	}
+	withLayout(DisplayResponse{Width: 10, LayoutVersion: 2, Layout: second})
+	// A late snapshot doesn't bring the old layout back
+	if got := withLayout(DisplayResponse{Width: 10, LayoutVersion: 2, Layout: first}); got.MapName != "second" {
+		t.Errorf("late snapshot brought back map %q", got.MapName)
+	}
+}
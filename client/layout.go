@@ -0,0 +1,89 @@
+package main
+
+import "fmt"
+
+const (
+	DEFAULT_COLUMNS = 80 // Terminal size assumed when the output isn't a terminal
+	DEFAULT_ROWS    = 24
+	SIDEBAR_WIDTH   = 32 // Columns the sidebar needs to be shown on the right of the map
+	SIDEBAR_GAP     = 2
+	MIN_VIEW_WIDTH  = 8 // Fewer map cells than this are too few to play
+	MIN_VIEW_HEIGHT = 5
+)
+
+// Where the sidebar is drawn
+const (
+	SIDEBAR_RIGHT uint8 = iota
+	SIDEBAR_BELOW
+)
+
+// Everything a frame is drawn from
+type Frame struct {
+	Response       DisplayResponse
+	Feed           []string
+	Theme          Theme
+	Width          int // Size of the terminal
+	Height         int
+	ShowScoreboard bool
+	ShowBindings   bool   // The bindings screen is shown in place of the map
+	Spectate       uint32 // Snake the camera follows while we are dead
+	Guest          uint32 // Second player at this keyboard, 0 when playing alone
+	Stats          string // Network statistics shown above the status bar, nothing hides them
+}
+
+// locals returns the players at this keyboard
+func (frame Frame) locals() []uint32 {
+	if frame.Guest == 0 {
+		return []uint32{userID}
+	}
+	return []uint32{userID, frame.Guest}
+}
+
+// Layout is where the map and the sidebar fit on the terminal
+type Layout struct {
+	ViewWidth  int // Map cells shown
+	ViewHeight int
+	CellWidth  int // Columns taken by a map cell, 1 on a compact map
+	Sidebar    uint8
+}
+
+// NewLayout fits the map on a terminal, keeping the status bar on its last row.
+// The map is shown with two columns per cell and the sidebar on its right if
+// they fit, then with one column per cell, and the sidebar goes below the map
+// when the terminal is too narrow for both. ok is false when too little of
+// the map fits to play.
+func NewLayout(width int, height int, mapWidth uint8, mapHeight uint8) (layout Layout, ok bool) {
+	viewWidth := min(int(mapWidth), VIEWPORT_WIDTH)
+	layout.ViewHeight = min(int(mapHeight), VIEWPORT_HEIGHT, height-3)
+	layout.CellWidth = 2
+	switch {
+	case boardWidth(viewWidth, 2)+SIDEBAR_GAP+SIDEBAR_WIDTH <= width:
+		layout.Sidebar = SIDEBAR_RIGHT
+	case boardWidth(viewWidth, 1)+SIDEBAR_GAP+SIDEBAR_WIDTH <= width:
+		layout.CellWidth = 1
+		layout.Sidebar = SIDEBAR_RIGHT
+	case boardWidth(viewWidth, 2) <= width:
+		layout.Sidebar = SIDEBAR_BELOW
+	default:
+		layout.CellWidth = 1
+		layout.Sidebar = SIDEBAR_BELOW
+		viewWidth = min(viewWidth, width-2)
+	}
+	layout.ViewWidth = viewWidth
+	return layout, layout.ViewWidth >= min(int(mapWidth), MIN_VIEW_WIDTH) && layout.ViewHeight >= min(int(mapHeight), MIN_VIEW_HEIGHT)
+}
+
+// boardWidth returns the columns taken by a map with its border
+func boardWidth(cells int, cellWidth int) int {
+	return (cells+1)*cellWidth + 1
+}
+
+// tooSmall returns the lines shown in place of a map that doesn't fit
+func tooSmall(width int, height int, mapWidth uint8, mapHeight uint8) []string {
+	needWidth := boardWidth(min(int(mapWidth), MIN_VIEW_WIDTH), 1)
+	needHeight := min(int(mapHeight), MIN_VIEW_HEIGHT) + 3
+	return []string{
+		"Terminal too small",
+		fmt.Sprintf("%dx%d, need %dx%d", width, height, needWidth, needHeight),
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"engine"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+)
+
+const (
+	OFFLINE_MAX_BOTS    = 4      // A room fits 5 snakes
+	MAPS_DIR            = "maps" // Maps of offline rooms, the ones of the server
+	SNAPSHOT_QUEUE_SIZE = 8      // Offline snapshots waiting to be drawn, later ones are dropped like lost packets
+)
+
+var (
+	offline         bool // Rooms are run by the client, nothing goes over the network
+	offlineBots     int  // Bots added to every room created offline
+	offlineMaps     map[string]*engine.MapFile
+	offlineRooms    = make(map[uint8]*engine.Room) // Guarded by offlineMutex
+	offlineSessions = make(map[uint32]*Session)    // Guarded by offlineMutex
+	// Never held while calling a room, rooms send snapshots holding their
+	// own locks
+	offlineMutex sync.Mutex
+)
+
+// connectOffline opens a session in the client, for a user of its own
+func connectOffline() *Session {
+	offlineMutex.Lock()
+	defer offlineMutex.Unlock()
+
+	id := rand.Uint32()
+	for _, exist := offlineSessions[id]; exist || id == 0; _, exist = offlineSessions[id] {
+		id = rand.Uint32()
+	}
+	session := &Session{
+		UserID:    id,
+		user:      &engine.User{ID: id},
+		snapshots: make(chan []byte, SNAPSHOT_QUEUE_SIZE),
+		closed:    make(chan bool),
+	}
+	offlineSessions[id] = session
+	return session
+}
+
+func closeOffline(session *Session) {
+	session.Command(CommandRequest{Quit: true})
+	offlineMutex.Lock()
+	delete(offlineSessions, session.UserID)
+	offlineMutex.Unlock()
+	close(session.closed)
+}
+
+// offlineCommand runs a command the way the server does. Rooms created
+// offline get offlineBots bots once their first player is in.
+func offlineCommand(session *Session, request CommandRequest) CommandResponse {
+	user := session.user
+
+	offlineMutex.Lock()
+	room, inRoom := offlineRooms[user.RoomID]
+	target, targetExist := offlineRooms[request.RoomID]
+	offlineMutex.Unlock()
+
+	response := CommandResponse{}
+	switch {
+	case request.JoinRoom:
+		response.JoinRoom = true
+		if !targetExist {
+			target = engine.NewRoom(request.RoomID, request.Settings, offlineMaps, sendOffline)
+		}
+		response.IsSuccess = target.AddPlayer(user, strings.ReplaceAll(string(request.Username[:]), "\x00", ""), request.SnakeShape, request.Team, request.Color)
+		if response.IsSuccess && !targetExist {
+			offlineMutex.Lock()
+			offlineRooms[request.RoomID] = target
+			offlineMutex.Unlock()
+			for i := 1; i <= offlineBots; i++ {
+				target.AddBot(fmt.Sprintf("bot%d", i))
+			}
+			go runOffline(target)
+		}
+	case request.ExitRoom, request.Quit:
+		if inRoom {
+			room.ExitRoom(user)
+		}
+		response.IsSuccess = true
+		response.ExitRoom = request.ExitRoom
+		response.Quit = request.Quit
+	case inRoom:
+		response.IsSuccess = room.Command(user, request)
+	}
+	return response
+}
+
+// runOffline runs a room until everyone left it
+func runOffline(room *engine.Room) {
+	room.Start()
+	offlineMutex.Lock()
+	if offlineRooms[room.ID] == room {
+		delete(offlineRooms, room.ID)
+	}
+	offlineMutex.Unlock()
+}
+
+func offlineMove(session *Session, request MoveRequest) {
+	if request.Ping {
+		sendOffline(session.UserID, engine.Pong(request))
+		return
+	}
+	offlineMutex.Lock()
+	room, exist := offlineRooms[session.user.RoomID]
+	offlineMutex.Unlock()
+	if exist {
+		room.Move(request)
+	}
+}
+
+// sendOffline queues a snapshot for the session of a player, dropping it
+// when the session is behind
+func sendOffline(userID uint32, snapshot []byte) {
+	offlineMutex.Lock()
+	session, exist := offlineSessions[userID]
+	offlineMutex.Unlock()
+	if !exist {
+		return
+	}
+	select {
+	case session.snapshots <- snapshot:
+	default:
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestOfflineFailedJoin(t *testing.T) {
+	defer func(bots int) { offlineBots = bots }(offlineBots)
+	offlineBots = OFFLINE_MAX_BOTS
+	local := connectOffline()
+	defer closeOffline(local)
+
+	request := CommandRequest{JoinRoom: true, RoomID: 200, Username: usernameRunes("a\x07"), SnakeShape: 'o'}
+	if response := local.Command(request); response.IsSuccess {
+		t.Error("joined with a control character in the username")
+	}
+	offlineMutex.Lock()
+	_, exist := offlineRooms[request.RoomID]
+	offlineMutex.Unlock()
+	if exist {
+		t.Error("room kept after its first player couldn't join")
+	}
+}
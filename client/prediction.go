@@ -0,0 +1,124 @@
+package main
+
+import (
+	"engine"
+	"slices"
+	"time"
+)
+
+// A move sent to the server and not taken by it yet
+type pendingMove struct {
+	Seq  uint32
+	Move rune
+	Sent time.Time
+}
+
+// Pending moves are forgotten once the server had the time to take this many
+// of them, in case their packet was lost
+const LOST_MOVE_MOVES = engine.InputQueueSize + 2
+
+// sendMove sends a move of a local player to the server, the next frame
+// draws their snake where the server is going to move it
+func sendMove(local *Session, move rune) {
+	stateMutex.Lock()
+	player, alive := ownSnake(lastResponse, local.UserID)
+	if alive {
+		last := player.Move
+		if len(local.pendingMoves) != 0 {
+			last = local.pendingMoves[len(local.pendingMoves)-1].Move
+		}
+		// The server drops the same moves, there's no point sending them
+		if !engine.Accepts(last, move) || len(local.pendingMoves) == engine.InputQueueSize {
+			stateMutex.Unlock()
+			return
+		}
+	}
+	local.moveSeq++
+	if alive {
+		local.pendingMoves = append(local.pendingMoves, pendingMove{local.moveSeq, move, time.Now()})
+	}
+	request := MoveRequest{UserID: local.UserID, Move: move, Seq: local.moveSeq}
+	stateMutex.Unlock()
+
+	local.Move(request)
+}
+
+// ownSnake returns a player when it has a snake moving on the map
+func ownSnake(response DisplayResponse, id uint32) (Player, bool) {
+	if response.Match.Phase != engine.MATCH_PLAYING {
+		return Player{}, false
+	}
+	for _, player := range response.Players {
+		if player.UserID == id {
+			return player, len(player.Snake) != 0
+		}
+	}
+	return Player{}, false
+}
+
+// reconcile forgets the pending moves the server took according to a new
+// snapshot. The prediction is always made again from the snapshot, so a
+// snake the server moved differently than predicted is corrected right away.
+func reconcile(response DisplayResponse) {
+	for _, local := range localSessions() {
+		player, alive := ownSnake(response, local.UserID)
+		if !alive {
+			local.pendingMoves = nil
+			continue
+		}
+		lostAfter := time.Duration(player.Speed) * time.Millisecond * LOST_MOVE_MOVES
+		local.pendingMoves = slices.DeleteFunc(local.pendingMoves, func(pending pendingMove) bool {
+			return pending.Seq <= player.InputSeq || time.Since(pending.Sent) > lostAfter
+		})
+	}
+}
+
+// predict returns the snapshot with the local snakes moved by every pending
+// move, the server moving them one cell for each of them
+func predict(response DisplayResponse) DisplayResponse {
+	for _, local := range localSessions() {
+		response = predictSnake(response, local)
+	}
+	return response
+}
+
+// predictSnake moves the snake of a local player by its pending moves. The
+// prediction stops at anything that would kill the snake.
+func predictSnake(response DisplayResponse, local *Session) DisplayResponse {
+	player, alive := ownSnake(response, local.UserID)
+	if !alive || len(local.pendingMoves) == 0 {
+		return response
+	}
+
+	blocked := make(map[Location]bool)
+	for _, wall := range response.Walls {
+		blocked[wall] = true
+	}
+	foods := make(map[Location]uint8)
+	for _, food := range response.Foods {
+		foods[food.Location] = food.Kind
+	}
+
+	snake := slices.Clone(player.Snake)
+	move := player.Move
+	for _, pending := range local.pendingMoves {
+		move = engine.Turn(move, pending.Move)
+		next, ok := engine.NextLoc(snake[0], move, response.Width, response.Height, response.Wrap)
+		if !ok || blocked[next] || (response.Settings.WinCondition == engine.WIN_ROYALE && !inZone(response.Zone, next)) {
+			break
+		}
+		kind, eats := foods[next]
+		snake = engine.Advance(snake, next, eats && kind != engine.FOOD_POISON)
+		player.Move = move
+	}
+	player.Snake = snake
+
+	players := slices.Clone(response.Players)
+	for i := range players {
+		if players[i].UserID == local.UserID {
+			players[i] = player
+		}
+	}
+	response.Players = players
+	return response
+}
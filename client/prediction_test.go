@@ -0,0 +1,111 @@
+package main
+
+import (
+	"engine"
+	"slices"
+	"testing"
+	"time"
+)
+
+// localSession makes a session the one at the keyboard for a test
+func localSession(t *testing.T, id uint32, pending ...pendingMove) *Session {
+	local := &Session{UserID: id, pendingMoves: pending}
+	oldSession, oldGuest := session, guest
+	session, guest = local, nil
+	t.Cleanup(func() { session, guest = oldSession, oldGuest })
+	return local
+}
+
+func TestPredictSnake(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings RoomSettings
+		wrap     bool
+		moves    string
+		walls    []Location
+		foods    []Food
+		zone     Zone
+		want     []Location
+	}{
+		{name: "none", want: []Location{{X: 5, Y: 5}, {X: 4, Y: 5}}},
+		{name: "straight", moves: ">", want: []Location{{X: 6, Y: 5}, {X: 5, Y: 5}}},
+		{name: "turns", moves: "v<", want: []Location{{X: 4, Y: 6}, {X: 5, Y: 6}}},
+		{name: "reverse", moves: "<", want: []Location{{X: 6, Y: 5}, {X: 5, Y: 5}}},
+		{name: "wall", moves: ">>", walls: []Location{{X: 7, Y: 5}}, want: []Location{{X: 6, Y: 5}, {X: 5, Y: 5}}},
+		{name: "edge", moves: "^^^^^^", want: []Location{{X: 5, Y: 0}, {X: 5, Y: 1}}},
+		{name: "wrap", wrap: true, moves: "^^^^^^", want: []Location{{X: 5, Y: 9}, {X: 5, Y: 0}}},
+		{name: "food", moves: ">", foods: []Food{{Location: Location{X: 6, Y: 5}, Kind: engine.FOOD_NORMAL}}, want: []Location{{X: 6, Y: 5}, {X: 5, Y: 5}, {X: 4, Y: 5}}},
+		{name: "poison", moves: ">", foods: []Food{{Location: Location{X: 6, Y: 5}, Kind: engine.FOOD_POISON}}, want: []Location{{X: 6, Y: 5}, {X: 5, Y: 5}}},
+		{
+			name:     "zone",
+			settings: RoomSettings{WinCondition: engine.WIN_ROYALE},
+			moves:    ">>",
+			zone:     Zone{X: 1, Y: 1, Width: 6, Height: 8},
+			want:     []Location{{X: 6, Y: 5}, {X: 5, Y: 5}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pending := []pendingMove{}
+			for i, move := range test.moves {
+				pending = append(pending, pendingMove{Seq: uint32(i + 1), Move: move})
+			}
+			local := localSession(t, 1, pending...)
+			snake := []Location{{X: 5, Y: 5}, {X: 4, Y: 5}}
+			zone := test.zone
+			if zone == (Zone{}) {
+				zone = Zone{Width: 10, Height: 10}
+			}
+			response := DisplayResponse{
+				Players:  []Player{{UserID: 1, Move: '>', Snake: snake}, {UserID: 2, Move: '>', Snake: []Location{{X: 0, Y: 0}}}},
+				Foods:    test.foods,
+				Width:    10,
+				Height:   10,
+				Wrap:     test.wrap,
+				Walls:    test.walls,
+				Settings: test.settings,
+				Match:    Match{Phase: engine.MATCH_PLAYING},
+				Zone:     zone,
+			}
+
+			predicted := predictSnake(response, local)
+			if got := predicted.Players[0].Snake; !slices.Equal(got, test.want) {
+				t.Errorf("predicted snake %v, want %v", got, test.want)
+			}
+			if !slices.Equal(response.Players[0].Snake, []Location{{X: 5, Y: 5}, {X: 4, Y: 5}}) || !slices.Equal(predicted.Players[1].Snake, []Location{{X: 0, Y: 0}}) {
+				t.Error("prediction changed the snapshot or another snake")
+			}
+		})
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	now := time.Now()
+	lost := now.Add(-time.Second * LOST_MOVE_MOVES)
+	local := localSession(t, 1,
+		pendingMove{Seq: 1, Move: 'v', Sent: now},
+		pendingMove{Seq: 2, Move: '<', Sent: lost},
+		pendingMove{Seq: 3, Move: '^', Sent: now},
+		pendingMove{Seq: 4, Move: '>', Sent: now},
+	)
+	response := DisplayResponse{
+		Players: []Player{{UserID: 1, Move: '>', Snake: []Location{{X: 5, Y: 5}}, Speed: 1000, InputSeq: 1}},
+		Match:   Match{Phase: engine.MATCH_PLAYING},
+	}
+
+	// Taken moves and the ones lost on the way are forgotten
+	reconcile(response)
+	seqs := []uint32{}
+	for _, pending := range local.pendingMoves {
+		seqs = append(seqs, pending.Seq)
+	}
+	if !slices.Equal(seqs, []uint32{3, 4}) {
+		t.Errorf("pending moves %v after reconciling, want [3 4]", seqs)
+	}
+
+	response.Players[0].Snake = nil
+	reconcile(response)
+	if len(local.pendingMoves) != 0 {
+		t.Errorf("dead snake kept pending moves %v", local.pendingMoves)
+	}
+}
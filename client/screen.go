@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+)
+
+// ANSI escape sequences used by the screen
+const (
+	ESC_ALT_SCREEN   = "\x1b[?1049h"
+	ESC_MAIN_SCREEN  = "\x1b[?1049l"
+	ESC_HIDE_CURSOR  = "\x1b[?25l"
+	ESC_SHOW_CURSOR  = "\x1b[?25h"
+	ESC_CLEAR_SCREEN = "\x1b[H\x1b[2J"
+	TAB_WIDTH        = 8
+)
+
+// A character on the terminal with the colour it is drawn in. A wide
+// character takes two cells, Text is empty on the second one.
+type Cell struct {
+	Text  string // Grapheme cluster drawn on the cell
+	Style string // SGR sequence the character is drawn with, empty for the default one
+}
+
+var blankCell = Cell{Text: " "}
+
+// Screen draws frames on the alternate screen of the terminal, writing only
+// the cells that changed since the previous frame
+type Screen struct {
+	out    io.Writer
+	cells  [][]Cell // What the terminal shows
+	active bool     // On the alternate screen
+	width  int      // Size of the terminal, frames are cut to it when set
+	height int
+}
+
+func NewScreen(out io.Writer) *Screen {
+	return &Screen{out: out}
+}
+
+// Enter switches to an empty alternate screen, the terminal content from
+// before is given back by Leave
+func (screen *Screen) Enter() {
+	screen.active = true
+	screen.cells = nil
+	io.WriteString(screen.out, ESC_ALT_SCREEN+ESC_HIDE_CURSOR+ESC_CLEAR_SCREEN)
+}
+
+func (screen *Screen) Leave() {
+	if !screen.active {
+		return
+	}
+	screen.active = false
+	io.WriteString(screen.out, COLOR_RESET+ESC_SHOW_CURSOR+ESC_MAIN_SCREEN)
+}
+
+// Resize tells the screen the size of the terminal. The terminal moves its
+// content around when resized, so the next frame is drawn again from scratch.
+func (screen *Screen) Resize(width int, height int) {
+	if width == screen.width && height == screen.height {
+		return
+	}
+	screen.width, screen.height = width, height
+	screen.cells = nil
+	if screen.active {
+		io.WriteString(screen.out, ESC_CLEAR_SCREEN)
+	}
+}
+
+// Draw shows a frame, its lines may contain SGR sequences and tabs. Whatever
+// doesn't fit on the terminal is cut, a line longer than the terminal would
+// wrap over the next ones.
+func (screen *Screen) Draw(frame string) {
+	lines := strings.Split(strings.TrimSuffix(frame, "\n"), "\n")
+	if screen.height > 0 && len(lines) > screen.height {
+		lines = lines[:screen.height]
+	}
+	cells := make([][]Cell, len(lines))
+	for y, line := range lines {
+		cells[y] = parseLine(line)
+		if screen.width > 0 && len(cells[y]) > screen.width {
+			cells[y] = cells[y][:screen.width]
+			if last := &cells[y][screen.width-1]; textWidth(last.Text) == 2 {
+				// Half of a wide character can't be drawn
+				*last = Cell{Text: " ", Style: last.Style}
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	cursorX, cursorY := -1, -1
+	style := ""
+	for y := 0; y < max(len(cells), len(screen.cells)); y++ {
+		var row, oldRow []Cell
+		if y < len(cells) {
+			row = cells[y]
+		}
+		if y < len(screen.cells) {
+			oldRow = screen.cells[y]
+		}
+		for x := 0; x < max(len(row), len(oldRow)); x++ {
+			cell, oldCell := cellAt(row, x), cellAt(oldRow, x)
+			if cell == oldCell || cell.Text == "" {
+				// The second cell of a wide character was drawn with it
+				continue
+			}
+			if x != cursorX || y != cursorY {
+				fmt.Fprintf(&out, "\x1b[%d;%dH", y+1, x+1)
+			}
+			if cell.Style != style {
+				if style != "" {
+					out.WriteString(COLOR_RESET)
+				}
+				out.WriteString(cell.Style)
+				style = cell.Style
+			}
+			out.WriteString(cell.Text)
+			cursorX, cursorY = x+textWidth(cell.Text), y
+		}
+	}
+	if style != "" {
+		out.WriteString(COLOR_RESET)
+	}
+	screen.cells = cells
+	screen.out.Write(out.Bytes())
+}
+
+func cellAt(row []Cell, x int) Cell {
+	if x < len(row) {
+		return row[x]
+	}
+	return blankCell
+}
+
+// parseLine splits a line into cells, expanding tabs and keeping the colours
+// set by the SGR sequences since the last reset. Control characters are
+// replaced so they can't move the cursor.
+func parseLine(line string) []Cell {
+	cells := []Cell{}
+	style := ""
+	state := -1
+	for line != "" {
+		switch line[0] {
+		case '\x1b':
+			end := strings.IndexByte(line, 'm')
+			if end < 0 {
+				return cells
+			}
+			if sequence := line[:end+1]; sequence == COLOR_RESET {
+				style = ""
+			} else {
+				// Colours add up until they are reset, like the text and
+				// background colours of a cell
+				style += sequence
+			}
+			line, state = line[end+1:], -1
+			continue
+		case '\t':
+			for {
+				cells = append(cells, Cell{Text: " ", Style: style})
+				if len(cells)%TAB_WIDTH == 0 {
+					break
+				}
+			}
+			line, state = line[1:], -1
+			continue
+		}
+		var char string
+		char, line, _, state = uniseg.FirstGraphemeClusterInString(line, state)
+		if r, _ := utf8.DecodeRuneInString(char); unicode.IsControl(r) {
+			char = string(unicode.ReplacementChar)
+		}
+		switch textWidth(char) {
+		case 0:
+			if base := lastChar(cells); base >= 0 {
+				cells[base].Text += char
+			}
+		case 2:
+			cells = append(cells, Cell{Text: char, Style: style}, Cell{Style: style})
+		default:
+			cells = append(cells, Cell{Text: char, Style: style})
+		}
+	}
+	return cells
+}
+
+// lastChar returns the index of the last cell starting a character, or -1
+func lastChar(cells []Cell) int {
+	for i := len(cells) - 1; i >= 0; i-- {
+		if cells[i].Text != "" {
+			return i
+		}
+	}
+	return -1
+}
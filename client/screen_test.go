@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"engine"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Run with -update to write the golden files again after a wanted change
+var update = flag.Bool("update", false, "update the golden files")
+
+// checkGolden compares the output with testdata/NAME.golden
+func checkGolden(t *testing.T, name string, output string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(output), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	golden, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(golden) != output {
+		t.Errorf("%s differs from %s:\n%s", name, path, output)
+	}
+}
+
+// drawFrames draws frames one after the other on a terminal of the given size,
+// the escape sequences written for each of them are made readable
+func drawFrames(width int, height int, frames ...string) string {
+	var out bytes.Buffer
+	screen := NewScreen(&out)
+	screen.Resize(width, height)
+	var result strings.Builder
+	for i, frame := range frames {
+		out.Reset()
+		screen.Draw(frame)
+		fmt.Fprintf(&result, "--- frame %d\n%s\n", i+1, strings.ReplaceAll(out.String(), "\x1b", `\e`))
+	}
+	return result.String()
+}
+
+func TestScreenDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		width  int // Unlimited when 0
+		height int
+		frames []string
+	}{
+		{"same", 0, 0, []string{"ab\ncd\n", "ab\ncd\n"}},
+		{"one_cell", 0, 0, []string{"#####\n# o #\n#####\n", "#####\n#  o#\n#####\n"}},
+		{"shrink", 0, 0, []string{"long line\nsecond\nthird\n", "short\nsecond\n"}},
+		{"grow", 0, 0, []string{"a\n", "a\nbb\nccc\n"}},
+		{"colors", 0, 0, []string{"a\x1b[31mbc\x1b[0md\n", "a\x1b[34mbc\x1b[0md\n", "abcd\n"}},
+		{"tabs", 0, 0, []string{"ab\tside\n", "abc\tside\n", "abcdefghij\tside\n"}},
+		{"cut", 6, 4, []string{"a long line\nb\nc\nd\ne\n", "a long line\nb\nc\nd\nE\n"}},
+		{"wide", 0, 0, []string{"a漢b\n", "a字b\n", "ab字\n", "abc\n"}},
+		{"wide_cut", 4, 0, []string{"abc漢\n", "ab漢\n"}},
+		{"marks", 0, 0, []string{"ae\u0301b\n", "aeb\n"}},
+		{"clusters", 0, 0, []string{"a🇯🇵b\n", "a👍🏽b\n", "ab👍🏽\n"}},
+		{"control", 0, 0, []string{"a\rb\x07\n"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checkGolden(t, "screen_"+test.name, drawFrames(test.width, test.height, test.frames...))
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	userID = 1
+	response := DisplayResponse{
+		Players: []Player{
+			{UserID: 1, Move: 'd', Snake: []Location{{X: 3, Y: 2}, {X: 2, Y: 2}, {X: 1, Y: 2}}, Username: "alice", SnakeShape: 'o', Speed: 750, Ready: true, Color: 196},
+			{UserID: 2, Move: 'w', Snake: []Location{{X: 6, Y: 4}, {X: 6, Y: 5}}, Username: "bob", SnakeShape: 'x', Speed: 750, Point: 3, Color: 33},
+		},
+		Foods:  []Food{{Location: Location{X: 5, Y: 1}, Kind: engine.FOOD_NORMAL}},
+		Speed:  750,
+		Width:  10,
+		Height: 14,
+		Walls:  []Location{{X: 0, Y: 0}, {X: 9, Y: 13}},
+		HostID: 1,
+		Match:  Match{Phase: engine.MATCH_LOBBY},
+		Tick:   1,
+		Time:   750,
+		Zone:   Zone{Width: 10, Height: 14},
+	}
+	tests := []struct {
+		name       string
+		mode       uint8
+		theme      string
+		width      int
+		height     int
+		scoreboard bool
+		bindings   bool
+		guest      uint32
+		stats      string
+	}{
+		{"lobby", COLOR_MODE_NONE, "classic", 100, 30, true, false, 0, ""},
+		{"lobby_256", COLOR_MODE_256, "ocean", 100, 30, true, false, 0, ""},
+		{"lobby_true", COLOR_MODE_TRUE, "dark", 100, 30, true, false, 0, ""},
+		{"compact", COLOR_MODE_NONE, "classic", 50, 20, true, false, 0, ""},
+		{"below", COLOR_MODE_NONE, "classic", 24, 30, true, false, 0, ""},
+		{"short", COLOR_MODE_NONE, "classic", 100, 9, true, false, 0, ""},
+		{"too_small", COLOR_MODE_NONE, "classic", 9, 6, true, false, 0, ""},
+		{"no_scoreboard", COLOR_MODE_NONE, "classic", 100, 30, false, false, 0, ""},
+		{"bindings", COLOR_MODE_NONE, "classic", 100, 30, true, true, 0, ""},
+		{"two_players", COLOR_MODE_256, "classic", 100, 30, true, false, 2, ""},
+		{"stats", COLOR_MODE_NONE, "classic", 100, 30, true, false, 0, "Ping 42ms ±3ms  Loss 1.0%  Tick 750ms  Snapshots 1/s  Players 2"},
+	}
+	defer func(mode uint8) { colorMode = mode }(colorMode)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			colorMode = test.mode
+			frame := Frame{
+				Response:       response,
+				Feed:           []string{"bob joined"},
+				Theme:          findTheme(test.theme),
+				Width:          test.width,
+				Height:         test.height,
+				ShowScoreboard: test.scoreboard,
+				ShowBindings:   test.bindings,
+				Guest:          test.guest,
+				Stats:          test.stats,
+			}
+			var out strings.Builder
+			render(&out, frame)
+			checkGolden(t, "render_"+test.name, drawFrames(test.width, test.height, out.String()))
+		})
+	}
+}
+
+func TestRenderWide(t *testing.T) {
+	userID = 1
+	response := DisplayResponse{
+		Players: []Player{
+			{UserID: 1, Move: 'd', Snake: []Location{{X: 5, Y: 2}, {X: 4, Y: 2}, {X: 3, Y: 2}, {X: 2, Y: 2}, {X: 1, Y: 2}}, Username: "漢字", SnakeShape: '🐍', Speed: 750},
+			{UserID: 2, Move: 'w', Snake: []Location{{X: 6, Y: 4}, {X: 6, Y: 5}, {X: 6, Y: 6}}, Username: "zoë", SnakeShape: 'é', Speed: 750},
+			{UserID: 3, Move: 'w', Snake: []Location{{X: 8, Y: 2}, {X: 8, Y: 3}, {X: 8, Y: 4}, {X: 8, Y: 5}}, Username: "🇯🇵👍🏽", SnakeShape: 'o', Speed: 750},
+		},
+		Speed:  750,
+		Width:  10,
+		Height: 8,
+		Match:  Match{Phase: engine.MATCH_LOBBY},
+		Zone:   Zone{Width: 10, Height: 8},
+	}
+	tests := []struct {
+		name   string
+		width  int
+		height int
+	}{
+		{"wide", 100, 20},
+		{"wide_compact", 20, 20},
+	}
+	defer func(mode uint8) { colorMode = mode }(colorMode)
+	colorMode = COLOR_MODE_NONE
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			frame := Frame{
+				Response:       response,
+				Theme:          findTheme("classic"),
+				Width:          test.width,
+				Height:         test.height,
+				ShowScoreboard: true,
+			}
+			var out strings.Builder
+			render(&out, frame)
+			checkGolden(t, "render_"+test.name, drawFrames(test.width, test.height, out.String()))
+		})
+	}
+}
+
+func TestTeamScoresMonochrome(t *testing.T) {
+	defer func(mode uint8) { colorMode = mode }(colorMode)
+	colorMode = COLOR_MODE_NONE
+	response := DisplayResponse{TeamScores: []uint32{3, 5}, Players: []Player{{Team: 1}, {Team: 2}}}
+	for _, line := range teamScores(response) {
+		if strings.Contains(line, "\x1b") {
+			t.Errorf("monochrome line %q has escape codes", line)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	crand "crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"engine"
+	"io"
+	"log"
+	"net"
+)
+
+// Session is the connection of a local player to the server, every local
+// player is a user of its own for the server. Offline sessions play in rooms
+// run by the client.
+type Session struct {
+	UserID       uint32
+	SymmetricKey []byte
+	TCP          *net.TCPConn
+	UDP          *net.UDPConn
+	user         *engine.User  // Offline user, nil when connected to a server
+	snapshots    chan []byte   // Snapshots of the offline room
+	closed       chan bool     // Closed with an offline session
+	moveSeq      uint32        // Seq of the last move sent
+	pendingMoves []pendingMove // Guarded by stateMutex
+}
+
+var (
+	session *Session // Session of the player at the keyboard
+	guest   *Session // Session of the second local player, nil when playing alone, guarded by stateMutex
+)
+
+// Connect opens a session on the server, or in the client when offline
+func Connect() *Session {
+	if offline {
+		return connectOffline()
+	}
+	session := &Session{}
+	remoteTCPAddr, err := net.ResolveTCPAddr(TCP, net.JoinHostPort(SERVER_IP, TCP_PORT))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	session.TCP, err = net.DialTCP(TCP, nil, remoteTCPAddr)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// Get public key
+	pubKeyBuffer := make([]byte, BUFFER_SIZE)
+	pubKeyBuffLength, _ := session.TCP.Read(pubKeyBuffer)
+	pubKeyTemp, err := x509.ParsePKIXPublicKey(pubKeyBuffer[:pubKeyBuffLength])
+	if err != nil {
+		log.Fatalln(err)
+	}
+	pubKey := pubKeyTemp.(*rsa.PublicKey)
+
+	// Send symmetric Key
+	session.SymmetricKey = make([]byte, 32)
+	if _, err := io.ReadFull(crand.Reader, session.SymmetricKey); err != nil {
+		log.Fatalln(err)
+	}
+
+	encryptedSKey, err := rsa.EncryptOAEP(sha256.New(), crand.Reader, pubKey, session.SymmetricKey, nil)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	session.TCP.Write(encryptedSKey)
+
+	// Ambil userId
+	userIdBuffer := make([]byte, BUFFER_SIZE)
+	length, _ := session.TCP.Read(userIdBuffer)
+	session.UserID = binary.BigEndian.Uint32(decryptMessage(userIdBuffer[:length], session.SymmetricKey))
+
+	remoteUdpAddr, err := net.ResolveUDPAddr(UDP, net.JoinHostPort(SERVER_IP, UDP_PORT))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	session.UDP, err = net.DialUDP(UDP, nil, remoteUdpAddr)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// Send udp address
+	udpAddrBuffer := new(bytes.Buffer)
+	udpAddrBuffer.WriteString(session.UDP.LocalAddr().String())
+	session.TCP.Write(encryptMessage(udpAddrBuffer.Bytes(), session.SymmetricKey))
+	return session
+}
+
+// Command sends a command to the server and waits for its response
+func (session *Session) Command(request CommandRequest) CommandResponse {
+	request.UserID = session.UserID
+	if session.user != nil {
+		return offlineCommand(session, request)
+	}
+	session.TCP.Write(encodeCommandRequest(request, session.SymmetricKey))
+
+	receiveBuffer := make([]byte, BUFFER_SIZE)
+	receiveLength, _ := session.TCP.Read(receiveBuffer)
+	return decodeCommandResponse(receiveBuffer[:receiveLength], session.SymmetricKey)
+}
+
+// Move sends a move to the room
+func (session *Session) Move(request MoveRequest) {
+	if session.user != nil {
+		offlineMove(session, request)
+		return
+	}
+	session.UDP.Write(encodeMoveRequest(request, session.SymmetricKey))
+}
+
+// Receive waits for the next snapshot of the room, ok is false once the
+// session is closed. Snapshots from the server are read into buffer.
+func (session *Session) Receive(buffer []byte) (snapshot []byte, ok bool) {
+	if session.user != nil {
+		select {
+		case snapshot := <-session.snapshots:
+			return snapshot, true
+		case <-session.closed:
+			return nil, false
+		}
+	}
+	length, _, err := session.UDP.ReadFromUDP(buffer)
+	if err != nil {
+		return nil, false
+	}
+	return buffer[:length], true
+}
+
+// Close leaves the server
+func (session *Session) Close() {
+	if session.user != nil {
+		closeOffline(session)
+		return
+	}
+	session.UDP.Close()
+	for {
+		if response := session.Command(CommandRequest{Quit: true}); response.IsSuccess && response.Quit {
+			break
+		}
+	}
+	session.TCP.Close()
+}
+
+// localSessions returns the sessions of everyone playing at this terminal,
+// stateMutex must be held
+func localSessions() []*Session {
+	if guest == nil {
+		return []*Session{session}
+	}
+	return []*Session{session, guest}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	PING_INTERVAL = time.Second
+	LOSS_WINDOW   = 100         // Snapshots the loss is measured over
+	RATE_WINDOW   = time.Second // Time the snapshot rate is measured over
+)
+
+// NetStats measures the connection to the room from pings and snapshot
+// numbers
+type NetStats struct {
+	RTT      time.Duration // Smoothed round trip time
+	Jitter   time.Duration // Smoothed difference between round trip times
+	pongs    int
+	received []uint32 // Numbers of the snapshots received within LOSS_WINDOW of the newest
+	first    uint32   // Number of the oldest snapshot received
+	newest   uint32
+	arrivals []time.Time // Snapshots received within RATE_WINDOW
+}
+
+var (
+	netStats    NetStats     // Guarded by stateMutex
+	showStats   bool         // Guarded by stateMutex
+	clientStart = time.Now() // Pings are sent with the time since
+)
+
+// sendPings pings the room of a session every PING_INTERVAL while playing
+func sendPings(session *Session) {
+	for range time.Tick(PING_INTERVAL) {
+		isPlayingMutex.Lock()
+		playing := isPlaying
+		isPlayingMutex.Unlock()
+		if playing {
+			session.Move(MoveRequest{UserID: session.UserID, Ping: true, Sent: clientTime(time.Now())})
+		}
+	}
+}
+
+// clientTime returns the milliseconds between clientStart and now
+func clientTime(now time.Time) uint32 {
+	return uint32(now.Sub(clientStart).Milliseconds())
+}
+
+// Pong measures the round trip of a ping sent at sent. The round trip time
+// and jitter are smoothed the way TCP smooths them.
+func (stats *NetStats) Pong(sent uint32, arrived time.Time) {
+	rtt := time.Duration(clientTime(arrived)-sent) * time.Millisecond
+	stats.pongs++
+	if stats.pongs == 1 {
+		stats.RTT = rtt
+		stats.Jitter = rtt / 2
+		return
+	}
+	stats.Jitter = (3*stats.Jitter + (stats.RTT - rtt).Abs()) / 4
+	stats.RTT = (7*stats.RTT + rtt) / 8
+}
+
+// Snapshot counts a snapshot of the room. Snapshots arriving twice are
+// counted once.
+func (stats *NetStats) Snapshot(number uint32, arrived time.Time) {
+	stats.arrivals = append(stats.arrivals, arrived)
+	for len(stats.arrivals) != 0 && arrived.Sub(stats.arrivals[0]) > RATE_WINDOW {
+		stats.arrivals = stats.arrivals[1:]
+	}
+
+	if slices.Contains(stats.received, number) || number+LOSS_WINDOW <= stats.newest {
+		return
+	}
+	if len(stats.received) == 0 || number < stats.first {
+		stats.first = number
+	}
+	stats.received = append(stats.received, number)
+	stats.newest = max(stats.newest, number)
+	stats.received = slices.DeleteFunc(stats.received, func(received uint32) bool {
+		return received+LOSS_WINDOW <= stats.newest
+	})
+}
+
+// Loss returns the share of the latest snapshots that never arrived
+func (stats *NetStats) Loss() float64 {
+	if len(stats.received) == 0 {
+		return 0
+	}
+	expected := min(stats.newest-stats.first+1, LOSS_WINDOW)
+	return 1 - float64(len(stats.received))/float64(expected)
+}
+
+// Line returns the statistics shown above the status bar, with the tick
+// rate and players of the room
+func (stats *NetStats) Line(response DisplayResponse, now time.Time) string {
+	parts := []string{"Ping -"}
+	if stats.pongs != 0 {
+		parts[0] = fmt.Sprintf("Ping %dms ±%dms", stats.RTT.Milliseconds(), stats.Jitter.Milliseconds())
+	}
+	rate := 0
+	for _, arrived := range stats.arrivals {
+		if now.Sub(arrived) <= RATE_WINDOW {
+			rate++
+		}
+	}
+	parts = append(parts,
+		fmt.Sprintf("Loss %.1f%%", stats.Loss()*100),
+		fmt.Sprintf("Tick %dms", response.Speed),
+		fmt.Sprintf("Snapshots %d/s", rate),
+		fmt.Sprintf("Players %d", len(response.Players)),
+	)
+	return strings.Join(parts, "  ")
+}
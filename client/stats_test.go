@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNetStats(t *testing.T) {
+	stats := NetStats{}
+	now := time.Now()
+	for number := uint32(1); number <= 20; number++ {
+		if number%4 == 2 {
+			// Lost on the way
+			continue
+		}
+		stats.Snapshot(number, now)
+	}
+	// Arriving twice counts once
+	stats.Snapshot(19, now)
+	if loss := stats.Loss(); loss != 0.25 {
+		t.Errorf("loss %v, want 0.25", loss)
+	}
+	// Only the latest LOSS_WINDOW snapshots count
+	stats.Snapshot(200, now)
+	if loss := stats.Loss(); loss != 0.99 {
+		t.Errorf("loss %v after a gap, want 0.99", loss)
+	}
+
+	sent := clientTime(now)
+	for _, rtt := range []time.Duration{40, 40, 60} {
+		stats.Pong(sent, clientStart.Add(time.Duration(sent)*time.Millisecond+rtt*time.Millisecond))
+	}
+	if stats.RTT != 42500*time.Microsecond || stats.Jitter != 16250*time.Microsecond {
+		t.Errorf("rtt %v jitter %v, want 42.5ms and 16.25ms", stats.RTT, stats.Jitter)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import "fmt"
+
+const (
+	COLOR_RESET = "\x1b[0m"
+	STYLE_BOLD  = "\x1b[1m" // Snakes of the players at this keyboard when there are two
+)
+
+var (
+	TEAM_NAMES  = []string{"Red", "Blue", "Green", "Yellow"}
+	TEAM_COLORS = []uint8{196, 33, 46, 226} // In the 256 colour palette
+)
+
+func teamName(team uint8) string {
+	if team == 0 || int(team) > len(TEAM_NAMES) {
+		return ""
+	}
+	return TEAM_NAMES[team-1]
+}
+
+// teamColor returns the escape code colouring the team, or nothing for
+// players without a team
+func teamColor(team uint8) string {
+	if team == 0 || int(team) > len(TEAM_COLORS) {
+		return ""
+	}
+	return paletteColor(TEAM_COLORS[team-1])
+}
+
+// snakeColor returns the escape code colouring a snake, the colour of its
+// team or the colour the player was given when joining
+func snakeColor(player Player) string {
+	if player.Team > 0 {
+		return teamColor(player.Team)
+	}
+	return paletteColor(player.Color)
+}
+
+// teamScores returns a sidebar line for every team with its score and players
+func teamScores(response DisplayResponse) []string {
+	lines := []string{}
+	for i, score := range response.TeamScores {
+		team := uint8(i + 1)
+		members := 0
+		for _, player := range response.Players {
+			if player.Team == team {
+				members++
+			}
+		}
+		line := fmt.Sprintf("%s - %d - %d players", teamName(team), score, members)
+		if color := teamColor(team); color != "" {
+			line = color + line + COLOR_RESET
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// terminalSize returns the columns and rows of the console window, ok is false
+// when the output isn't a console
+func terminalSize() (width int, height int, ok bool) {
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(windows.Handle(os.Stdout.Fd()), &info); err != nil {
+		return 0, 0, false
+	}
+	window := info.Window
+	return int(window.Right-window.Left) + 1, int(window.Bottom-window.Top) + 1, true
+}
+
+// notifyResize does nothing, Windows has no resize signal and frames check the
+// size of the console every time they are drawn
+func notifyResize(resized chan<- os.Signal) {}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"engine"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+const WIDE_FALLBACK = 'o' // Drawn instead of wide characters on compact maps
+
+// normalizeName returns a username in NFC, keeping the first characters that
+// fit in NAME_SIZE runes, or why it can't be used. A character is never cut in
+// half, one that doesn't fit whole is dropped.
+func normalizeName(name string) (string, error) {
+	if !utf8.ValidString(name) {
+		return "", errors.New("username isn't valid UTF-8")
+	}
+	chars := characters(strings.TrimSpace(name))
+	if len(chars) == 0 {
+		return "", errors.New("username must not be blank")
+	}
+	var username strings.Builder
+	runes := 0
+	for _, char := range chars {
+		runes += utf8.RuneCountInString(char)
+		if runes > engine.NAME_SIZE {
+			break
+		}
+		for _, r := range char {
+			if !engine.ValidShape(r) {
+				return "", fmt.Errorf("username: %q can't be drawn", char)
+			}
+		}
+		username.WriteString(char)
+	}
+	if username.Len() == 0 {
+		return "", fmt.Errorf("username: %q is longer than %d runes", chars[0], engine.NAME_SIZE)
+	}
+	return username.String(), nil
+}
+
+// normalizeShape returns the rune a snake shape is drawn with, or why it
+// can't be used
+func normalizeShape(shape string) (rune, error) {
+	if !utf8.ValidString(shape) {
+		return 0, errors.New("snake shape isn't valid UTF-8")
+	}
+	chars := characters(strings.TrimSpace(shape))
+	switch {
+	case len(chars) == 0:
+		return 0, errors.New("snake shape must not be blank")
+	case len(chars) > 1:
+		return 0, errors.New("snake shape must be one character")
+	}
+	r, size := utf8.DecodeRuneInString(chars[0])
+	if size != len(chars[0]) {
+		return 0, fmt.Errorf("snake shape: %q is more than one rune", chars[0])
+	}
+	if !engine.ValidShape(r) {
+		return 0, fmt.Errorf("snake shape: %q can't be drawn", chars[0])
+	}
+	return r, nil
+}
+
+// characters splits text in NFC into the grapheme clusters the terminal draws
+// as single characters. Variation selectors are dropped, they only pick
+// between the text and emoji look of a character.
+func characters(text string) []string {
+	text = norm.NFC.String(strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Variation_Selector, r) {
+			return -1
+		}
+		return r
+	}, text))
+	chars := []string{}
+	state := -1
+	for len(text) != 0 {
+		var char string
+		char, text, _, state = uniseg.FirstGraphemeClusterInString(text, state)
+		chars = append(chars, char)
+	}
+	return chars
+}
+
+// textWidth returns the columns the terminal draws text on, 2 for every wide
+// character and 0 for marks and format characters
+func textWidth(text string) int {
+	return uniseg.StringWidth(text)
+}
+
+// padText pads text with spaces to the given columns
+func padText(text string, columns int) string {
+	return text + strings.Repeat(" ", max(columns-textWidth(text), 0))
+}
@@ -0,0 +1,66 @@
+package main
+
+import "testing"
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string // Empty when the name is rejected
+	}{
+		{"alice", "alice"},
+		{" bob ", "bob"},
+		{"alexander", "alexa"},
+		{"zoë", "zoë"},     // Composed to one rune
+		{"漢字かな文字", "漢字かな文"}, // Cut by characters, not bytes
+		{"❤️", "❤"},         // Variation selectors are dropped
+		{"🇯🇵", "🇯🇵"},
+		{"👍🏽ab", "👍🏽ab"},
+		{"abcd🇯🇵", "abcd"}, // Flags and skin tones are kept or dropped whole
+		{"abcd👍🏽", "abcd"},
+		{"abc🇯🇵", "abc🇯🇵"},
+		{"", ""},
+		{"a\x07b", ""},
+		{"a​b", ""},    // Zero width space
+		{"g̃", ""},     // No rune of its own
+		{"a\xffb", ""}, // Invalid UTF-8
+		{"👩‍💻", ""},    // Joined emoji
+	}
+	for _, test := range tests {
+		got, err := normalizeName(test.name)
+		if got != test.want || (err == nil) != (test.want != "") {
+			t.Errorf("normalizeName(%q) = %q, %v, want %q", test.name, got, err, test.want)
+		}
+	}
+}
+
+func TestNormalizeShape(t *testing.T) {
+	tests := []struct {
+		shape string
+		want  rune // 0 when the shape is rejected
+	}{
+		{"o", 'o'},
+		{"é", 'é'},
+		{"🐍", '🐍'},
+		{"❤️", '❤'},
+		{"", 0},
+		{"ab", 0},
+		{"\t", 0},
+		{"́", 0},
+		{"🇯🇵", 0},
+		{"👍🏽", 0},
+	}
+	for _, test := range tests {
+		got, err := normalizeShape(test.shape)
+		if got != test.want || (err == nil) != (test.want != 0) {
+			t.Errorf("normalizeShape(%q) = %q, %v, want %q", test.shape, got, err, test.want)
+		}
+	}
+}
+
+func TestTextWidth(t *testing.T) {
+	for text, want := range map[string]int{"a": 1, "é": 1, "漢": 2, "🐍": 2, "Ａ": 2, "́": 0, "‍": 0, "🇯🇵": 2, "👍🏽": 2, "ab漢": 4} {
+		if got := textWidth(text); got != want {
+			t.Errorf("textWidth(%q) = %d, want %d", text, got, want)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Colours the terminal can show
+const (
+	COLOR_MODE_NONE uint8 = iota // Snakes are only told apart by their shape
+	COLOR_MODE_16
+	COLOR_MODE_256
+	COLOR_MODE_TRUE
+)
+
+type Color struct {
+	R uint8
+	G uint8
+	B uint8
+}
+
+// Colours of everything on the map that isn't a snake
+type Theme struct {
+	Name       string
+	Wall       Color
+	Food       Color
+	Zone       Color  // Cells outside of the zone
+	Background *Color // nil keeps the background of the terminal
+}
+
+var THEMES = []Theme{
+	{Name: "classic", Wall: Color{200, 200, 200}, Food: Color{255, 215, 0}, Zone: Color{205, 0, 0}},
+	{Name: "dark", Wall: Color{110, 110, 130}, Food: Color{255, 180, 0}, Zone: Color{140, 20, 20}, Background: &Color{18, 18, 24}},
+	{Name: "ocean", Wall: Color{90, 170, 210}, Food: Color{255, 200, 90}, Zone: Color{220, 70, 70}, Background: &Color{0, 30, 60}},
+	{Name: "forest", Wall: Color{130, 95, 50}, Food: Color{250, 90, 80}, Zone: Color{170, 150, 60}, Background: &Color{12, 40, 12}},
+}
+
+// The 16 colours of the terminal, the way most terminals show them
+var BASIC_COLORS = []Color{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0}, {0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0}, {92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// Levels of the 6x6x6 colour cube of the 256 colour palette
+var CUBE_LEVELS = []uint8{0, 95, 135, 175, 215, 255}
+
+var (
+	colorMode = detectColorMode()
+	theme     = findTheme(os.Getenv("SNAKE_THEME")) // Guarded by stateMutex
+)
+
+// detectColorMode guesses the colours of the terminal from the environment.
+// SNAKE_COLORS can be set to none, 16, 256 or true when the guess is wrong.
+func detectColorMode() uint8 {
+	switch os.Getenv("SNAKE_COLORS") {
+	case "none":
+		return COLOR_MODE_NONE
+	case "16":
+		return COLOR_MODE_16
+	case "256":
+		return COLOR_MODE_256
+	case "true":
+		return COLOR_MODE_TRUE
+	}
+
+	if _, set := os.LookupEnv("NO_COLOR"); set {
+		return COLOR_MODE_NONE
+	}
+	switch os.Getenv("COLORTERM") {
+	case "truecolor", "24bit":
+		return COLOR_MODE_TRUE
+	}
+	if os.Getenv("WT_SESSION") != "" {
+		// Windows Terminal doesn't set TERM
+		return COLOR_MODE_TRUE
+	}
+	term := os.Getenv("TERM")
+	switch {
+	case term == "" || term == "dumb":
+		return COLOR_MODE_NONE
+	case strings.Contains(term, "256color"):
+		return COLOR_MODE_256
+	}
+	return COLOR_MODE_16
+}
+
+// findTheme returns the theme with the given name, or the first theme
+func findTheme(name string) Theme {
+	for _, theme := range THEMES {
+		if theme.Name == name {
+			return theme
+		}
+	}
+	return THEMES[0]
+}
+
+func themeNames() string {
+	names := []string{}
+	for _, theme := range THEMES {
+		names = append(names, theme.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+// Fg returns the escape code drawing text in the colour closest to color the
+// terminal has
+func (color Color) Fg() string {
+	return color.code(false)
+}
+
+// Bg returns the escape code drawing the background in the colour closest to
+// color the terminal has
+func (color Color) Bg() string {
+	return color.code(true)
+}
+
+func (color Color) code(background bool) string {
+	layer := 38
+	if background {
+		layer = 48
+	}
+	switch colorMode {
+	case COLOR_MODE_TRUE:
+		return fmt.Sprintf("\x1b[%d;2;%d;%d;%dm", layer, color.R, color.G, color.B)
+	case COLOR_MODE_256:
+		return fmt.Sprintf("\x1b[%d;5;%dm", layer, color.cubeIndex())
+	case COLOR_MODE_16:
+		code := 30 + color.basicIndex()
+		if code >= 38 {
+			// Bright colours
+			code += 60 - 8
+		}
+		if background {
+			code += 10
+		}
+		return fmt.Sprintf("\x1b[%dm", code)
+	}
+	return ""
+}
+
+// paletteColor returns the escape code drawing text in a colour of the 256
+// colour palette, the colours snakes are given
+func paletteColor(index uint8) string {
+	if colorMode == COLOR_MODE_256 {
+		return fmt.Sprintf("\x1b[38;5;%dm", index)
+	}
+	return paletteRGB(index).Fg()
+}
+
+// paletteRGB returns the colour of the 256 colour palette at index
+func paletteRGB(index uint8) Color {
+	switch {
+	case index < 16:
+		return BASIC_COLORS[index]
+	case index < 232:
+		cube := index - 16
+		return Color{CUBE_LEVELS[cube/36], CUBE_LEVELS[cube/6%6], CUBE_LEVELS[cube%6]}
+	}
+	gray := 8 + (index-232)*10
+	return Color{gray, gray, gray}
+}
+
+// cubeIndex returns the closest colour of the colour cube of the palette
+func (color Color) cubeIndex() int {
+	return 16 + 36*closestLevel(color.R) + 6*closestLevel(color.G) + closestLevel(color.B)
+}
+
+func closestLevel(value uint8) int {
+	closest := 0
+	for i, level := range CUBE_LEVELS {
+		if absDiff(level, value) < absDiff(CUBE_LEVELS[closest], value) {
+			closest = i
+		}
+	}
+	return closest
+}
+
+// basicIndex returns the closest of the 16 colours of the terminal
+func (color Color) basicIndex() int {
+	closest, best := 0, -1
+	for i, basic := range BASIC_COLORS {
+		r, g, b := absDiff(basic.R, color.R), absDiff(basic.G, color.G), absDiff(basic.B, color.B)
+		distance := r*r + g*g + b*b
+		if best == -1 || distance < best {
+			closest, best = i, distance
+		}
+	}
+	return closest
+}
+
+func absDiff(a uint8, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
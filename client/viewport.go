@@ -0,0 +1,182 @@
+package main
+
+import "strings"
+
+const (
+	VIEWPORT_WIDTH  = 30 // Max cells shown horizontally
+	VIEWPORT_HEIGHT = 30 // Max cells shown vertically
+)
+
+// Viewport is the part of the map drawn on the terminal. Every map cell takes
+// two columns, or one on a compact map, and the viewport is surrounded by a
+// one cell border.
+type Viewport struct {
+	X         int // First map column shown
+	Y         int // First map row shown
+	Width     int
+	Height    int
+	CellWidth int // Columns taken by a map cell
+	MapWidth  int
+	MapHeight int
+	Wrap      bool
+	Grid      [][]string // Grapheme cluster drawn on every column
+	Colors    [][]string // Escape code colouring every cell of Grid, if any
+	// Escape code of the background of the map, inside of the border
+	Background string
+}
+
+// NewViewport creates a viewport of the map centred on focus, as big as the
+// layout has room for. Border sides on the edge of the map are drawn with '#',
+// or ':' when the edges wrap around. Sides where the map continues outside of
+// the viewport are drawn with '.'.
+func NewViewport(mapWidth uint8, mapHeight uint8, wrap bool, focus Location, layout Layout) *Viewport {
+	view := &Viewport{
+		Width:     layout.ViewWidth,
+		Height:    layout.ViewHeight,
+		CellWidth: layout.CellWidth,
+		MapWidth:  int(mapWidth),
+		MapHeight: int(mapHeight),
+		Wrap:      wrap,
+	}
+	view.X = cameraStart(int(focus.X), view.MapWidth, view.Width, wrap)
+	view.Y = cameraStart(int(focus.Y), view.MapHeight, view.Height, wrap)
+
+	edge, corner := "#", "#"
+	if wrap {
+		edge, corner = ":", "+"
+	}
+	top, bottom, left, right := edge, edge, edge, edge
+	if view.Height < view.MapHeight && (wrap || view.Y > 0) {
+		top = "."
+	}
+	if view.Height < view.MapHeight && (wrap || view.Y+view.Height < view.MapHeight) {
+		bottom = "."
+	}
+	if view.Width < view.MapWidth && (wrap || view.X > 0) {
+		left = "."
+	}
+	if view.Width < view.MapWidth && (wrap || view.X+view.Width < view.MapWidth) {
+		right = "."
+	}
+
+	columns := boardWidth(view.Width, view.CellWidth)
+	view.Grid = make([][]string, view.Height+2)
+	view.Colors = make([][]string, view.Height+2)
+	for y := range view.Grid {
+		view.Grid[y] = make([]string, columns)
+		view.Colors[y] = make([]string, columns)
+		for x := range view.Grid[y] {
+			view.Grid[y][x] = " "
+		}
+		view.Grid[y][0] = left
+		view.Grid[y][columns-1] = right
+	}
+	for x := 0; x < columns; x += view.CellWidth {
+		view.Grid[0][x] = top
+		view.Grid[view.Height+1][x] = bottom
+	}
+	view.Grid[0][0], view.Grid[0][columns-1] = corner, corner
+	view.Grid[view.Height+1][0], view.Grid[view.Height+1][columns-1] = corner, corner
+
+	return view
+}
+
+// Set draws r on a map cell, cells outside of the viewport are ignored
+func (view *Viewport) Set(loc Location, r rune) {
+	view.SetText(loc, string(r))
+}
+
+// SetText draws a grapheme cluster on a map cell. A wide character fills both
+// columns of a cell, on a compact map it is drawn as WIDE_FALLBACK.
+func (view *Viewport) SetText(loc Location, char string) {
+	if row, column, ok := view.cell(loc); ok {
+		if view.CellWidth == 1 && textWidth(char) == 2 {
+			char = string(WIDE_FALLBACK)
+		}
+		view.Grid[row][column] = char
+	}
+}
+
+// SetColor colours every cell of locs with the escape code color
+func (view *Viewport) SetColor(locs []Location, color string) {
+	for _, loc := range locs {
+		if row, column, ok := view.cell(loc); ok {
+			view.Colors[row][column] = color
+		}
+	}
+}
+
+// ColorBorder colours the border around the map with the escape code color
+func (view *Viewport) ColorBorder(color string) {
+	last := len(view.Grid) - 1
+	for y := range view.Grid {
+		for x := range view.Grid[y] {
+			if y == 0 || y == last || x == 0 || x == len(view.Grid[y])-1 {
+				view.Colors[y][x] = color
+			}
+		}
+	}
+}
+
+// Row returns a row of the grid with the escape codes of its coloured cells
+func (view *Viewport) Row(y int) string {
+	var row strings.Builder
+	last := len(view.Grid[y]) - 1
+	wide := false
+	for x, char := range view.Grid[y] {
+		if wide {
+			// Covered by the wide character before
+			wide = false
+			continue
+		}
+		wide = textWidth(char) == 2
+		color := view.Colors[y][x]
+		if y > 0 && y < len(view.Grid)-1 && x > 0 && x < last {
+			color = view.Background + color
+		}
+		if color != "" {
+			row.WriteString(color + char + COLOR_RESET)
+			continue
+		}
+		row.WriteString(char)
+	}
+	return row.String()
+}
+
+// cell returns where a map cell is drawn on the grid, ok is false when the
+// cell is outside of the viewport
+func (view *Viewport) cell(loc Location) (row int, column int, ok bool) {
+	x := int(loc.X) - view.X
+	y := int(loc.Y) - view.Y
+	if view.Wrap {
+		x = (x + view.MapWidth) % view.MapWidth
+		y = (y + view.MapHeight) % view.MapHeight
+	}
+	if x < 0 || y < 0 || x >= view.Width || y >= view.Height {
+		return 0, 0, false
+	}
+	return y + 1, (x + 1) * view.CellWidth, true
+}
+
+func inZone(zone Zone, loc Location) bool {
+	return loc.X >= zone.X && loc.X < zone.X+zone.Width && loc.Y >= zone.Y && loc.Y < zone.Y+zone.Height
+}
+
+// cameraStart returns the first cell shown so focus stays in the middle of
+// the viewport. Without wrapping the camera stops at the edge of the map.
+func cameraStart(focus int, mapSize int, viewSize int, wrap bool) int {
+	if viewSize >= mapSize {
+		return 0
+	}
+	start := focus - viewSize/2
+	if wrap {
+		return (start + mapSize) % mapSize
+	}
+	if start > mapSize-viewSize {
+		start = mapSize - viewSize
+	}
+	if start < 0 {
+		start = 0
+	}
+	return start
+}
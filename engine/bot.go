@@ -0,0 +1,104 @@
+package engine
+
+import "math/rand"
+
+const (
+	botShape       = 'x'
+	botTrapPenalty = 1000 // Score lost by a move leading where the bot can't fit
+	botPoisonCost  = 20   // Score lost by eating poison
+)
+
+// AddBot adds a snake moved by the room itself, reporting whether it joined.
+// Bots only join rooms a human plays in, they are always ready and never host.
+func (room *Room) AddBot(username string) bool {
+	room.playersMut.Lock()
+	defer room.playersMut.Unlock()
+
+	id := rand.Uint32()
+	for _, exist := room.players[id]; exist || id == 0; _, exist = room.players[id] {
+		id = rand.Uint32()
+	}
+	player := &Player{
+		UserID:     id,
+		Username:   username,
+		SnakeShape: botShape,
+		Ready:      true,
+		Bot:        true,
+	}
+	// A room of bots only would never end
+	humans := 0
+	for _, other := range room.players {
+		if !other.Bot {
+			humans++
+		}
+	}
+	return humans != 0 && room.Join(player, 0, 0)
+}
+
+// BotMove picks the move of a bot. It heads to the closest food through cells
+// that don't kill it, avoiding the ones leading where its body can't fit.
+// Keeping its direction wins ties.
+func (room *Room) BotMove(player *Player) rune {
+	best, bestScore := player.Move, 0
+	found := false
+	for _, move := range append([]rune{player.Move}, directions...) {
+		if Turn(player.Move, move) != move {
+			continue
+		}
+		next, ok := room.NextLoc(player.Snake[0], move)
+		if !ok || !room.InZone(next) {
+			continue
+		}
+		cell := room.roomMap[next.Y][next.X]
+		if cell == CELL_WALL || (cell == CELL_SNAKE && player.Ghost == 0) {
+			continue
+		}
+
+		score := -room.FoodDistance(next)
+		if room.OpenCells(next, len(player.Snake)+1) <= len(player.Snake) {
+			score -= botTrapPenalty
+		}
+		if kind, exist := room.foods[next]; exist && kind == FOOD_POISON {
+			score -= botPoisonCost
+		}
+		if !found || score > bestScore {
+			best, bestScore, found = move, score, true
+		}
+	}
+	return best
+}
+
+// FoodDistance returns the distance from loc to the closest food other than
+// poison, or the size of the map when there is none
+func (room *Room) FoodDistance(loc Location) int {
+	closest := int(room.settings.Width) + int(room.settings.Height)
+	for food, kind := range room.foods {
+		if kind != FOOD_POISON {
+			closest = min(closest, room.Distance(loc, food))
+		}
+	}
+	return closest
+}
+
+// OpenCells counts the empty cells reachable from loc inside the zone, up to
+// limit
+func (room *Room) OpenCells(loc Location, limit int) int {
+	seen := map[Location]bool{loc: true}
+	queue := []Location{loc}
+	for len(queue) != 0 && len(seen) < limit {
+		cell := queue[0]
+		queue = queue[1:]
+		for _, move := range directions {
+			next, ok := room.NextLoc(cell, move)
+			if !ok || seen[next] || !room.InZone(next) {
+				continue
+			}
+			if kind := room.roomMap[next.Y][next.X]; kind != CELL_EMPTY && kind != CELL_FOOD {
+				continue
+			}
+			seen[next] = true
+			queue = append(queue, next)
+		}
+	}
+	return len(seen)
+}
@@ -0,0 +1,21 @@
+package engine
+
+import "testing"
+
+func TestAddBot(t *testing.T) {
+	room := newTestRoom(RoomSettings{})
+	if room.AddBot("bot1") {
+		t.Error("bot joined an empty room")
+	}
+	if !room.AddPlayer(&User{ID: 1}, "human", 'o', 0, 0) || !room.AddBot("bot1") || !room.AddPlayer(&User{ID: 2}, "guest", 'o', 0, 0) {
+		t.Fatal("bot couldn't join humans")
+	}
+	room.Leave(1)
+	if room.hostID != 2 {
+		t.Errorf("host is %d after the host left, want the guest", room.hostID)
+	}
+	room.Leave(2)
+	if room.AddBot("bot2") {
+		t.Error("bot joined a room of bots")
+	}
+}
@@ -0,0 +1,70 @@
+package engine
+
+import "math/rand"
+
+// ResetCells rebuilds the index of the empty cells of roomMap. Every later
+// change of roomMap goes through SetCell so the index stays up to date.
+func (room *Room) ResetCells() {
+	room.freeCells = nil
+	room.freeIndex = make([][]int, len(room.roomMap))
+	for y, row := range room.roomMap {
+		room.freeIndex[y] = make([]int, len(row))
+		for x, cell := range row {
+			room.freeIndex[y][x] = -1
+			if cell == CELL_EMPTY {
+				room.freeIndex[y][x] = len(room.freeCells)
+				room.freeCells = append(room.freeCells, Location{X: uint8(x), Y: uint8(y)})
+			}
+		}
+	}
+}
+
+func (room *Room) SetCell(loc Location, cell uint8) {
+	old := room.roomMap[loc.Y][loc.X]
+	room.roomMap[loc.Y][loc.X] = cell
+	if old != CELL_EMPTY && cell == CELL_EMPTY {
+		room.freeIndex[loc.Y][loc.X] = len(room.freeCells)
+		room.freeCells = append(room.freeCells, loc)
+	} else if old == CELL_EMPTY && cell != CELL_EMPTY {
+		// Move the last free cell into the hole
+		i := room.freeIndex[loc.Y][loc.X]
+		last := room.freeCells[len(room.freeCells)-1]
+		room.freeCells[i] = last
+		room.freeIndex[last.Y][last.X] = i
+		room.freeCells = room.freeCells[:len(room.freeCells)-1]
+		room.freeIndex[loc.Y][loc.X] = -1
+	}
+}
+
+// FindLoc picks a random empty cell inside the zone, ok is false when the
+// zone has no empty cell left
+func (room *Room) FindLoc() (Location, bool) {
+	if len(room.freeCells) == 0 {
+		return Location{}, false
+	}
+	for try := 0; try < maxSpawnTries; try++ {
+		loc := room.freeCells[rand.Intn(len(room.freeCells))]
+		if room.InZone(loc) {
+			return loc, true
+		}
+	}
+
+	// Most empty cells are outside of a small zone
+	inZone := []Location{}
+	for _, loc := range room.freeCells {
+		if room.InZone(loc) {
+			inZone = append(inZone, loc)
+		}
+	}
+	if len(inZone) == 0 {
+		return Location{}, false
+	}
+	return inZone[rand.Intn(len(inZone))], true
+}
+
+// BoardFull reports whether nothing can spawn anymore because the zone has
+// no empty cell left
+func (room *Room) BoardFull() bool {
+	_, ok := room.FindLoc()
+	return !ok
+}
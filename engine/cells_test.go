@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestFreeCells(t *testing.T) {
+	room := newTestRoom(RoomSettings{})
+	checkCells(t, room)
+
+	users := []*User{{ID: 1}, {ID: 2}}
+	for _, user := range users {
+		if !room.AddPlayer(user, "snake", 'o', 0, 0) {
+			t.Fatal("no room for a snake")
+		}
+	}
+	player := room.players[1]
+	checkCells(t, room)
+	for range 3 {
+		room.SpawnFood()
+	}
+	checkCells(t, room)
+
+	// Eat a food put in front of the snake
+	next, _ := room.NextLoc(player.Snake[0], player.Move)
+	if room.roomMap[next.Y][next.X] == CELL_EMPTY {
+		addFood(room, next, FOOD_NORMAL)
+	}
+	room.MovePlayers(map[*Player]rune{player: player.Move})
+	checkCells(t, room)
+
+	room.Shrink(player, 1)
+	checkCells(t, room)
+	room.KillPlayer(player, DEATH_WALL, nil)
+	checkCells(t, room)
+	room.Respawn(player)
+	checkCells(t, room)
+	room.Leave(2)
+	checkCells(t, room)
+}
+
+func TestBoardFull(t *testing.T) {
+	room := newTestRoom(RoomSettings{})
+	last := Location{7, 3}
+	for y := range room.roomMap {
+		for x := range room.roomMap[y] {
+			if loc := (Location{uint8(x), uint8(y)}); loc != last {
+				room.SetCell(loc, CELL_WALL)
+			}
+		}
+	}
+	if loc, ok := room.FindLoc(); !ok || loc != last {
+		t.Errorf("FindLoc() = %v, %v, want %v", loc, ok, last)
+	}
+	addFood(room, last, FOOD_NORMAL)
+	if !room.BoardFull() {
+		t.Error("board with no empty cell isn't full")
+	}
+	checkCells(t, room)
+	delete(room.foods, last)
+	room.SetCell(last, CELL_EMPTY)
+	if room.BoardFull() {
+		t.Error("board with an empty cell is full")
+	}
+	checkCells(t, room)
+}
+
+// TestCellsRandomTicks plays rooms of snakes moving at random and checks the
+// map after every tick
+func TestCellsRandomTicks(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings RoomSettings
+	}{
+		{"endless", RoomSettings{}},
+		{"teams", RoomSettings{Teams: 2}},
+		{"royale", RoomSettings{Teams: 2, WinCondition: WIN_ROYALE, ShrinkTime: 1, Lives: 3}},
+		{"burst", RoomSettings{Wrap: true, FoodSpawner: SPAWNER_BURST}},
+	}
+	moves := []rune{'>', '<', '^', 'v'}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			settings := test.settings
+			// Mostly power-ups, so snakes often share cells
+			settings.FoodWeights = [FOOD_KINDS]uint8{4, 1, 2, 3, 3, 3}
+			settings.FoodCaps = [FOOD_KINDS]uint8{0, 0, 0, 0, 0, 0}
+			for game := 0; game < 20; game++ {
+				room := newTestRoom(settings)
+				room.AddPlayer(&User{ID: 1}, "human", 'o', 0, 0)
+				for i := 1; i <= 4; i++ {
+					room.AddBot("bot")
+				}
+				room.StartRound()
+				for tick := 0; tick < 200 && room.match.Phase == MATCH_PLAYING; tick++ {
+					room.UpdateMatch(100)
+					room.UpdateTimers(100)
+					playing := map[*Player]rune{}
+					for _, player := range room.players {
+						if player.Snake != nil && room.match.Phase == MATCH_PLAYING {
+							playing[player] = moves[rand.Intn(len(moves))]
+						}
+					}
+					room.MovePlayers(playing)
+					room.SpawnFoods()
+					checkCells(t, room)
+					if t.Failed() {
+						t.Fatalf("game %d broke on tick %d", game, tick)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package engine
+
+import "strings"
+
+const CHAT_SIZE = 60 // Runes of a chat message
+
+// Chat shows a message to everyone in the room with the next snapshot
+func (room *Room) Chat(user *User, message [CHAT_SIZE]rune) bool {
+	text := strings.TrimSpace(strings.ReplaceAll(string(message[:]), "\x00", ""))
+
+	room.playersMut.Lock()
+	defer room.playersMut.Unlock()
+
+	player, exist := room.players[user.ID]
+	if !exist || text == "" || !validText(text) {
+		return false
+	}
+	room.events = append(room.events, Event{Type: EVENT_CHAT, UserID: user.ID, Username: player.Username, Text: text})
+	return true
+}
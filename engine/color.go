@@ -0,0 +1,28 @@
+package engine
+
+// Colours given to snakes whose player picked none or a colour already taken,
+// in the 256 colour palette of terminals
+var playerColors = []uint8{196, 33, 46, 226, 201, 51, 208, 129, 118, 213, 39, 220}
+
+// PickColor gives the player the colour they picked, or the first colour of
+// playerColors nobody in the room has. Colour 0 means no preference since it's
+// the black of the palette, the colour of the background.
+func (room *Room) PickColor(player *Player, color uint8) {
+	taken := make(map[uint8]bool)
+	for _, other := range room.players {
+		if other != player {
+			taken[other.Color] = true
+		}
+	}
+	if color != 0 && !taken[color] {
+		player.Color = color
+		return
+	}
+	for _, color := range playerColors {
+		if !taken[color] {
+			player.Color = color
+			return
+		}
+	}
+	player.Color = playerColors[room.joinCount%uint64(len(playerColors))]
+}
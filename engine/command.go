@@ -0,0 +1,61 @@
+package engine
+
+// User is someone playing in at most one room, over the network or at the
+// keyboard of an offline client
+type User struct {
+	ID     uint32
+	RoomID uint8
+}
+
+type CommandRequest struct {
+	UserID         uint32
+	JoinRoom       bool
+	RoomID         uint8
+	ExitRoom       bool
+	Quit           bool
+	Username       [NAME_SIZE]rune
+	SnakeShape     rune
+	Settings       RoomSettings // Used when the room is created or changed
+	Ready          bool
+	Unready        bool
+	StartMatch     bool // Host only
+	ChangeSettings bool // Host only
+	Kick           bool // Host only
+	TransferHost   bool // Host only
+	TargetID       uint32
+	Team           uint8 // Team picked when joining or changed, 0 for any team
+	ChangeTeam     bool
+	Color          uint8 // Colour picked when joining, 0 for any colour
+	Chat           bool
+	Message        [CHAT_SIZE]rune
+}
+
+type MoveRequest struct {
+	UserID uint32
+	Move   rune
+	Seq    uint32 // Numbers the moves of a player so snapshots can tell which ones were taken
+	Ping   bool   // Asks for a pong instead of moving
+	Sent   uint32 // Time the ping was sent in milliseconds of the client, sent back with the pong
+}
+
+// Command runs a lobby or host command of a player in the room. Joining and
+// leaving rooms is up to whoever keeps the rooms.
+func (room *Room) Command(user *User, command CommandRequest) bool {
+	switch {
+	case command.Ready, command.Unready:
+		return room.SetReady(user, command.Ready)
+	case command.StartMatch:
+		return room.StartMatch(user)
+	case command.ChangeSettings:
+		return room.ChangeSettings(user, command.Settings)
+	case command.Kick:
+		return room.Kick(user, command.TargetID)
+	case command.TransferHost:
+		return room.TransferHost(user, command.TargetID)
+	case command.ChangeTeam:
+		return room.ChangeTeam(user, command.Team)
+	case command.Chat:
+		return room.Chat(user, command.Message)
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+// Package engine holds the rooms and rules of the game shared by the server
+// and the client, so the client can predict its own snake the way the server
+// moves it and run rooms by itself when offline.
+package engine
+
+type Location struct {
+	X uint8
+	Y uint8
+}
+
+// Moves a player can queue ahead on the server, later ones are dropped
+const InputQueueSize = 3
+
+var opposites = map[rune]rune{'>': '<', '<': '>', '^': 'v', 'v': '^'}
+
+// Turn returns the direction of a snake heading to current once it is told to
+// move, unless it would reverse onto itself
+func Turn(current rune, move rune) rune {
+	if opposite, exist := opposites[move]; !exist || opposite == current {
+		return current
+	}
+	return move
+}
+
+// Accepts reports whether a move is worth queueing when last is the direction
+// the snake has once the moves already queued are done. Moves repeating or
+// reversing last change nothing.
+func Accepts(last rune, move rune) bool {
+	return Turn(last, move) != last
+}
+
+// NextLoc returns the cell in front of loc on a width x height map. On
+// wrapping maps the edges continue on the opposite side, otherwise ok is false
+// when loc is on the edge the move heads to.
+func NextLoc(loc Location, move rune, width uint8, height uint8, wrap bool) (next Location, ok bool) {
+	x := int(loc.X)
+	y := int(loc.Y)
+	switch move {
+	case '>':
+		x++
+	case '<':
+		x--
+	case 'v':
+		y++
+	case '^':
+		y--
+	}
+	if wrap {
+		x = (x + int(width)) % int(width)
+		y = (y + int(height)) % int(height)
+	} else if x < 0 || y < 0 || x >= int(width) || y >= int(height) {
+		return loc, false
+	}
+	return Location{uint8(x), uint8(y)}, true
+}
+
+// Advance moves the head of snake to next, the rest of the body follows. A
+// growing snake keeps its tail. The snake is changed in place unless it grows.
+func Advance(snake []Location, next Location, grows bool) []Location {
+	if grows {
+		return append([]Location{next}, snake...)
+	}
+	copy(snake[1:], snake[:len(snake)-1])
+	snake[0] = next
+	return snake
+}
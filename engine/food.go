@@ -0,0 +1,156 @@
+package engine
+
+import "math/rand"
+
+// Food kinds
+const (
+	FOOD_NORMAL uint8 = iota // One segment and one point
+	FOOD_BONUS               // One segment and BONUS_POINTS points
+	FOOD_POISON              // Cuts poisonShrink segments and points
+	FOOD_SPEED               // Moves twice as fast for boostTime
+	FOOD_GHOST               // Passes through bodies for ghostTime
+	FOOD_MAGNET              // Pulls nearby food for magnetTime
+	FOOD_KINDS
+)
+
+const BONUS_POINTS = 5
+
+const (
+	poisonShrink = 3
+	boostTime    = 5000 // Milliseconds
+	ghostTime    = 5000 // Milliseconds
+	magnetTime   = 8000 // Milliseconds
+	magnetRange  = 5    // Food this close to a magnet head is pulled in
+)
+
+var (
+	defaultFoodWeights = [FOOD_KINDS]uint8{20, 4, 3, 2, 2, 2}
+	defaultFoodCaps    = [FOOD_KINDS]uint8{0, 2, 2, 1, 1, 1}
+)
+
+// Food is a food cell in snapshots
+type Food struct {
+	Location
+	Kind uint8
+}
+
+// PickFood picks the kind of a new food by the spawn weights of the room,
+// skipping kinds already on the map as many times as their cap
+func (room *Room) PickFood() uint8 {
+	counts := [FOOD_KINDS]uint8{}
+	for _, kind := range room.foods {
+		counts[kind]++
+	}
+
+	weights := [FOOD_KINDS]int{}
+	total := 0
+	for kind := range weights {
+		limit := room.settings.FoodCaps[kind]
+		if limit == 0 || counts[kind] < limit {
+			weights[kind] = int(room.settings.FoodWeights[kind])
+			total += weights[kind]
+		}
+	}
+	if total == 0 {
+		return FOOD_NORMAL
+	}
+	pick := rand.Intn(total)
+	for kind, weight := range weights {
+		if pick < weight {
+			return uint8(kind)
+		}
+		pick -= weight
+	}
+	return FOOD_NORMAL
+}
+
+// SpawnFood puts a new food where the spawner of the room wants it, reporting
+// whether there was room for it
+func (room *Room) SpawnFood() bool {
+	loc, ok := room.spawner.Place(room)
+	if !ok {
+		return false
+	}
+	room.foods[loc] = room.PickFood()
+	room.SetCell(loc, CELL_FOOD)
+	return true
+}
+
+// Eat gives the player the points and effect of a food its head moved on.
+// Growing is done by MovePlayers.
+func (room *Room) Eat(player *Player, kind uint8) {
+	switch kind {
+	case FOOD_BONUS:
+		player.Point += BONUS_POINTS
+	case FOOD_POISON:
+		room.Shrink(player, poisonShrink)
+		return
+	case FOOD_SPEED:
+		player.Boost = boostTime
+	case FOOD_GHOST:
+		player.Ghost = ghostTime
+	case FOOD_MAGNET:
+		player.Magnet = magnetTime
+	}
+	if kind != FOOD_BONUS {
+		player.Point++
+	}
+}
+
+// Shrink cuts up to n segments off the tail and as many points, a snake
+// keeps at least its head and a single point
+func (room *Room) Shrink(player *Player, n int) {
+	n = min(n, len(player.Snake)-1)
+	cut := player.Snake[len(player.Snake)-n:]
+	player.Snake = player.Snake[:len(player.Snake)-n]
+	for _, loc := range cut {
+		room.LeaveCell(loc)
+	}
+	player.Point -= min(player.Point-1, uint32(n))
+}
+
+// PullFood moves every food within magnetRange of the head of the player one
+// cell closer to it
+func (room *Room) PullFood(player *Player) {
+	head := player.Snake[0]
+	pulled := []Location{}
+	for loc := range room.foods {
+		if distance := room.Distance(loc, head); distance > 1 && distance <= magnetRange {
+			pulled = append(pulled, loc)
+		}
+	}
+
+	for _, loc := range pulled {
+		dx := room.Direction(loc.X, head.X, room.settings.Width)
+		dy := room.Direction(loc.Y, head.Y, room.settings.Height)
+		next := loc
+		if dx != 0 && (dy == 0 || abs(int(head.X)-int(loc.X)) >= abs(int(head.Y)-int(loc.Y))) {
+			next.X = uint8((int(loc.X) + dx + int(room.settings.Width)) % int(room.settings.Width))
+		} else {
+			next.Y = uint8((int(loc.Y) + dy + int(room.settings.Height)) % int(room.settings.Height))
+		}
+		if room.roomMap[next.Y][next.X] != CELL_EMPTY || !room.InZone(next) {
+			continue
+		}
+		room.foods[next] = room.foods[loc]
+		delete(room.foods, loc)
+		room.SetCell(loc, CELL_EMPTY)
+		room.SetCell(next, CELL_FOOD)
+	}
+}
+
+// Direction returns the step, -1, 0 or 1, taking from to to the shortest way
+// on an axis of the given size
+func (room *Room) Direction(from uint8, to uint8, size uint8) int {
+	delta := int(to) - int(from)
+	if room.settings.Wrap && abs(delta) > int(size)/2 {
+		delta = -delta
+	}
+	switch {
+	case delta > 0:
+		return 1
+	case delta < 0:
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,16 @@
+package engine
+
+import "testing"
+
+func TestPullFoodUnderSnake(t *testing.T) {
+	magnet := &Player{Snake: []Location{{5, 5}, {5, 6}, {5, 7}}, Magnet: 1000}
+	// Still on the tail the magnet snake leaves
+	protected := &Player{Snake: []Location{{5, 7}, {6, 7}}, Protected: 1000}
+	room := newTestRoom(RoomSettings{}, magnet, protected)
+	addFood(room, Location{5, 8}, FOOD_NORMAL)
+	room.MovePlayers(map[*Player]rune{magnet: '^'})
+	if _, exist := room.foods[Location{5, 7}]; exist {
+		t.Error("food pulled under the protected snake")
+	}
+	checkCells(t, room)
+}
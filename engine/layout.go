@@ -0,0 +1,75 @@
+package engine
+
+// RoomLayout holds what only changes with the map and settings of a room. It
+// is too big to go with every snapshot, so snapshots only carry it for a few
+// snapshots after it changed or a player joined, then every layoutInterval
+// snapshots in case those were lost.
+type RoomLayout struct {
+	Version  uint32 // Counts the layouts of the room
+	MapName  string
+	Walls    []byte // One bit per cell row by row, set on walls
+	Settings RoomSettings
+}
+
+const (
+	layoutRepeats  = 3  // Snapshots carrying a new layout
+	layoutInterval = 20 // Snapshots between two sends of an unchanged layout
+)
+
+// UpdateLayout makes a new layout from the map and settings of the room
+func (room *Room) UpdateLayout() {
+	room.layout = RoomLayout{
+		Version:  room.layout.Version + 1,
+		MapName:  room.MapName(),
+		Walls:    EncodeWalls(room.Walls(), room.settings.Width),
+		Settings: room.settings,
+	}
+	room.layoutSends = layoutRepeats
+}
+
+// LayoutDue reports whether the next snapshot carries the layout
+func (room *Room) LayoutDue() bool {
+	if room.layoutSends > 0 {
+		room.layoutSends--
+		return true
+	}
+	return room.snapshot%layoutInterval == 0
+}
+
+// EncodeWalls packs walls of a map width cells wide into one bit per cell
+func EncodeWalls(walls []Location, width uint8) []byte {
+	if len(walls) == 0 {
+		return nil
+	}
+	bits := []byte{}
+	for _, wall := range walls {
+		i := int(wall.Y)*int(width) + int(wall.X)
+		for len(bits) <= i/8 {
+			bits = append(bits, 0)
+		}
+		bits[i/8] |= 1 << (i % 8)
+	}
+	return bits
+}
+
+// DecodeWalls returns the walls packed by EncodeWalls
+func DecodeWalls(bits []byte, width uint8) []Location {
+	walls := []Location{}
+	for i := range len(bits) * 8 {
+		if bits[i/8]&(1<<(i%8)) != 0 {
+			walls = append(walls, Location{uint8(i % int(width)), uint8(i / int(width))})
+		}
+	}
+	return walls
+}
+
+// UseLayout fills in the parts of a snapshot its layout holds. A snapshot of
+// another layout than the one given has no walls until its own one arrives.
+func (response *DisplayResponse) UseLayout(layout RoomLayout) {
+	if layout.Version != response.LayoutVersion {
+		return
+	}
+	response.MapName = layout.MapName
+	response.Walls = DecodeWalls(layout.Walls, response.Width)
+	response.Settings = layout.Settings
+}
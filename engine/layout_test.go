@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestWalls(t *testing.T) {
+	walls := []Location{{0, 0}, {7, 0}, {8, 0}, {3, 2}, {99, 99}}
+	bits := EncodeWalls(walls, 100)
+	if len(bits) != 1250 {
+		t.Errorf("walls of a 100x100 map take %d bytes, want 1250", len(bits))
+	}
+	if got := DecodeWalls(bits, 100); !slices.Equal(got, walls) {
+		t.Errorf("DecodeWalls() = %v, want %v", got, walls)
+	}
+	if bits := EncodeWalls(nil, 100); bits != nil || len(DecodeWalls(bits, 100)) != 0 {
+		t.Errorf("EncodeWalls(nil) = %v", bits)
+	}
+}
+
+// TestLayoutSnapshots sends snapshots of a map full of walls and checks only
+// some carry its layout
+func TestLayoutSnapshots(t *testing.T) {
+	mapFile := &MapFile{Name: "maze", Width: maxMapSize, Height: maxMapSize}
+	for y := range maxMapSize {
+		for x := range maxMapSize {
+			if x%2 == 0 || y == 0 {
+				mapFile.Walls = append(mapFile.Walls, Location{uint8(x), uint8(y)})
+			}
+		}
+	}
+	var sent []byte
+	settings := RoomSettings{}
+	copy(settings.Map[:], []rune("maze"))
+	room := NewRoom(1, settings, map[string]*MapFile{"maze": mapFile}, func(_ uint32, snapshot []byte) {
+		sent = snapshot
+	})
+	if !room.AddPlayer(&User{ID: 1}, "alice", 'o', 0, 0) {
+		t.Fatal("no room for a snake")
+	}
+
+	carried := []uint32{}
+	for range 2 * layoutInterval {
+		room.snapshot++
+		room.sendLayout = room.LayoutDue()
+		var wg sync.WaitGroup
+		wg.Add(1)
+		room.SendResponse(room.players[1], &wg)
+
+		var response DisplayResponse
+		if err := json.Unmarshal(sent, &response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Layout == nil {
+			if len(sent) > 1500 {
+				t.Errorf("snapshot %d without layout is %d bytes", room.snapshot, len(sent))
+			}
+			continue
+		}
+		carried = append(carried, room.snapshot)
+		if len(sent) > 4000 {
+			t.Errorf("snapshot %d with layout is %d bytes", room.snapshot, len(sent))
+		}
+		response.UseLayout(*response.Layout)
+		if response.MapName != "maze" || !slices.Equal(response.Walls, mapFile.Walls) || response.Settings != room.settings {
+			t.Errorf("snapshot %d has a different layout than the room", room.snapshot)
+		}
+	}
+	if want := []uint32{1, 2, 3, layoutInterval, 2 * layoutInterval}; !slices.Equal(carried, want) {
+		t.Errorf("snapshots %v carried the layout, want %v", carried, want)
+	}
+
+	// A snapshot of a newer layout has no walls until the layout arrives
+	response := DisplayResponse{Width: maxMapSize, LayoutVersion: room.layout.Version + 1}
+	response.UseLayout(room.layout)
+	if response.Walls != nil || response.MapName != "" {
+		t.Error("snapshot took the walls of another layout")
+	}
+}
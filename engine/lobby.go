@@ -0,0 +1,122 @@
+package engine
+
+// AllReady reports whether every player in the room is ready to play
+func (room *Room) AllReady() bool {
+	for _, player := range room.players {
+		if !player.Ready {
+			return false
+		}
+	}
+	return true
+}
+
+func (room *Room) IsHost(user *User) bool {
+	return user.RoomID == room.ID && room.hostID == user.ID
+}
+
+// SetReady marks the player as ready or not. Unready players stop a
+// countdown unless the host forced the match to start.
+func (room *Room) SetReady(user *User, ready bool) bool {
+	room.playersMut.Lock()
+	defer room.playersMut.Unlock()
+
+	player, exist := room.players[user.ID]
+	if !exist {
+		return false
+	}
+	player.Ready = ready
+	return true
+}
+
+// StartMatch lets the host start the countdown without waiting for everyone
+// to be ready
+func (room *Room) StartMatch(user *User) bool {
+	room.playersMut.Lock()
+	defer room.playersMut.Unlock()
+
+	if !room.IsHost(user) || room.match.Phase != MATCH_LOBBY || room.playerNum < room.MinPlayers() {
+		return false
+	}
+	room.forceStart = true
+	room.match.Phase = MATCH_COUNTDOWN
+	room.match.TimeLeft = countdownTime
+	return true
+}
+
+// ChangeSettings lets the host change the settings of the room between
+// rounds. The map is rebuilt and every snake respawns on it.
+func (room *Room) ChangeSettings(user *User, settings RoomSettings) bool {
+	room.playersMut.Lock()
+	defer room.playersMut.Unlock()
+
+	if !room.IsHost(user) || room.match.Phase != MATCH_LOBBY {
+		return false
+	}
+	room.settings = NormalizeSettings(settings)
+	room.mapFile = nil
+	room.foods = make(map[Location]uint8)
+	room.InitialMap()
+	for _, player := range room.players {
+		player.Snake = nil
+		player.Lives = room.settings.Lives
+		player.Spectator = false
+		room.JoinTeam(player, player.Team)
+		room.SpawnSnake(player)
+	}
+	room.UpdateSpeed()
+	return true
+}
+
+// TransferHost makes another player of the room the host
+func (room *Room) TransferHost(user *User, targetID uint32) bool {
+	room.playersMut.Lock()
+	defer room.playersMut.Unlock()
+
+	target, exist := room.players[targetID]
+	if !room.IsHost(user) || !exist {
+		return false
+	}
+	room.SetHost(target)
+	return true
+}
+
+// Kick removes another player from the room and tells them about it
+func (room *Room) Kick(user *User, targetID uint32) bool {
+	room.playersMut.Lock()
+	target, exist := room.players[targetID]
+	isHost := room.IsHost(user)
+	if isHost && exist && target.Bot {
+		room.Leave(targetID)
+		room.playersMut.Unlock()
+		return true
+	}
+	room.playersMut.Unlock()
+
+	if !isHost || !exist || targetID == user.ID {
+		return false
+	}
+	// Only tell the target when they didn't leave in the meantime
+	if room.ExitRoom(target.user) {
+		room.SendKicked(target.user)
+	}
+	return true
+}
+
+func (room *Room) SetHost(player *Player) {
+	room.hostID = player.UserID
+	room.events = append(room.events, Event{Type: EVENT_HOST, UserID: player.UserID, Username: player.Username})
+}
+
+// PickHost gives the room to the player who has been in it the longest, bots
+// never host
+func (room *Room) PickHost() {
+	var oldest *Player
+	for _, player := range room.players {
+		if !player.Bot && (oldest == nil || player.joinOrder < oldest.joinOrder) {
+			oldest = player
+		}
+	}
+	if oldest != nil {
+		room.SetHost(oldest)
+	}
+}
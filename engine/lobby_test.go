@@ -0,0 +1,129 @@
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// newLobby creates a room with a human player for every user, the first one
+// hosting it
+func newLobby(t *testing.T, settings RoomSettings, users ...*User) *Room {
+	t.Helper()
+	room := newTestRoom(settings)
+	// Room for the wake ups ExitRoom sends to HandleMainChannel, which isn't
+	// running
+	room.mainChannel = make(chan MoveRequest, 8)
+	for i, user := range users {
+		user.ID = uint32(i + 1)
+		if !room.AddPlayer(user, "snake", 'o', 0, 0) {
+			t.Fatal("no room for a snake")
+		}
+	}
+	return room
+}
+
+func TestStartMatch(t *testing.T) {
+	host, guest := &User{}, &User{}
+	room := newLobby(t, RoomSettings{WinCondition: WIN_LAST_ALIVE}, host)
+	if room.StartMatch(host) {
+		t.Error("last snake alive started with a single player")
+	}
+	guest.ID = 2
+	room.AddPlayer(guest, "guest", 'o', 0, 0)
+	if room.StartMatch(guest) {
+		t.Error("a player who isn't the host started the match")
+	}
+	if !room.StartMatch(host) || room.match.Phase != MATCH_COUNTDOWN {
+		t.Fatal("host couldn't start the match")
+	}
+
+	// Nobody is ready, the countdown goes on anyway
+	room.UpdateMatch(countdownTime - 100)
+	if room.match.Phase != MATCH_COUNTDOWN {
+		t.Errorf("forced countdown stopped in phase %d", room.match.Phase)
+	}
+	room.UpdateMatch(100)
+	if room.match.Phase != MATCH_PLAYING || room.forceStart {
+		t.Errorf("forced match is in phase %d, forced %v", room.match.Phase, room.forceStart)
+	}
+	if room.StartMatch(host) {
+		t.Error("host started a match already playing")
+	}
+}
+
+func TestChangeSettings(t *testing.T) {
+	host, guest := &User{}, &User{}
+	room := newLobby(t, RoomSettings{}, host, guest)
+	settings := RoomSettings{Width: 20, Height: 15, Lives: 2}
+	if room.ChangeSettings(guest, settings) {
+		t.Error("a player who isn't the host changed the settings")
+	}
+	if !room.ChangeSettings(host, settings) {
+		t.Fatal("host couldn't change the settings")
+	}
+	if len(room.roomMap) != 15 || len(room.roomMap[0]) != 20 || room.layout.Settings.Width != 20 {
+		t.Errorf("map is %dx%d after changing it to 20x15", len(room.roomMap[0]), len(room.roomMap))
+	}
+	for _, player := range room.players {
+		if len(player.Snake) == 0 || player.Lives != 2 {
+			t.Errorf("player %d has snake %v and %d lives on the new map", player.UserID, player.Snake, player.Lives)
+		}
+	}
+	checkCells(t, room)
+
+	room.match.Phase = MATCH_PLAYING
+	if room.ChangeSettings(host, RoomSettings{}) {
+		t.Error("host changed the settings during a round")
+	}
+}
+
+func TestHost(t *testing.T) {
+	host, guest, third := &User{}, &User{}, &User{}
+	room := newLobby(t, RoomSettings{}, host, guest, third)
+	if room.hostID != 1 {
+		t.Fatalf("room is hosted by %d, not its creator", room.hostID)
+	}
+	if room.TransferHost(guest, 3) || room.TransferHost(host, 4) {
+		t.Error("host transferred by someone else or to someone out of the room")
+	}
+	if !room.TransferHost(host, 3) || !room.IsHost(third) || room.IsHost(host) {
+		t.Fatal("host couldn't transfer the room")
+	}
+
+	// The host leaving gives the room to whoever has been in it the longest
+	room.ExitRoom(third)
+	if room.hostID != 1 {
+		t.Errorf("room is hosted by %d after the host left, want the oldest player", room.hostID)
+	}
+	if last := room.events[len(room.events)-1]; last.Type != EVENT_HOST || last.UserID != 1 {
+		t.Errorf("last event is %+v, want the new host", last)
+	}
+}
+
+func TestKick(t *testing.T) {
+	var kicked []uint32
+	host, guest, third := &User{}, &User{}, &User{}
+	room := newLobby(t, RoomSettings{}, host, guest, third)
+	room.send = func(userID uint32, snapshot []byte) {
+		var response DisplayResponse
+		if json.Unmarshal(snapshot, &response) == nil && response.Kicked {
+			kicked = append(kicked, userID)
+		}
+	}
+	if room.Kick(guest, 3) || room.Kick(host, 1) || room.Kick(host, 4) {
+		t.Error("kicked by someone else, the host or someone out of the room")
+	}
+	if !room.Kick(host, 2) {
+		t.Fatal("host couldn't kick a player")
+	}
+	if _, exist := room.players[2]; exist || guest.RoomID != 0 || len(kicked) != 1 || kicked[0] != 2 {
+		t.Errorf("kicked player is still in room %d, told %v", guest.RoomID, kicked)
+	}
+
+	// A player who left for another room stays there
+	room.ExitRoom(third)
+	third.RoomID = 2
+	if room.ExitRoom(third) || third.RoomID != 2 {
+		t.Errorf("leaving a room again took the player out of room %d", third.RoomID)
+	}
+}
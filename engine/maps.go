@@ -0,0 +1,156 @@
+package engine
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A map file starts with "key: value" metadata lines followed by a "---"
+// line and the ASCII grid of the map, one line per row:
+//
+//	name: Pillars
+//	size: 30x30
+//	spawn: 2,2,5,5
+//	---
+//	..............................
+//	....##..............##........
+//
+// '#' is a wall, '.' is an empty cell and '$' is an empty cell food spawns on
+// with SPAWNER_POINTS. size is optional and has to match the grid when given.
+// Every spawn line adds a spawn zone as X,Y,WIDTH,HEIGHT, snakes only spawn
+// inside spawn zones when the map has any.
+type MapFile struct {
+	Name   string
+	Width  uint8
+	Height uint8
+	Walls  []Location
+	Spawns []SpawnZone
+	Foods  []Location // Food spawn points
+}
+
+type SpawnZone struct {
+	X      uint8
+	Y      uint8
+	Width  uint8
+	Height uint8
+}
+
+const MAP_EXTENSION = ".map"
+
+// LoadMaps reads every map file in dir keyed by file name without extension.
+// A missing dir only means there is no map other than the empty arena.
+func LoadMaps(dir string) map[string]*MapFile {
+	maps := make(map[string]*MapFile)
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+MAP_EXTENSION))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	for _, path := range paths {
+		mapFile, err := ReadMapFile(path)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		maps[strings.TrimSuffix(filepath.Base(path), MAP_EXTENSION)] = mapFile
+	}
+	return maps
+}
+
+func ReadMapFile(path string) (*MapFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	mapFile := &MapFile{Name: strings.TrimSuffix(filepath.Base(path), MAP_EXTENSION)}
+	var width, height int
+	var rows []string
+	isGrid := false
+
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if isGrid {
+			if line != "" {
+				rows = append(rows, line)
+			}
+			continue
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		} else if line == "---" {
+			isGrid = true
+			continue
+		}
+
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			return nil, fmt.Errorf("%s:%d: expected key: value", path, lineNum)
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "name":
+			mapFile.Name = value
+		case "size":
+			if _, err := fmt.Sscanf(value, "%dx%d", &width, &height); err != nil {
+				return nil, fmt.Errorf("%s:%d: size must be WIDTHxHEIGHT", path, lineNum)
+			}
+		case "spawn":
+			var x, y, w, h int
+			if _, err := fmt.Sscanf(value, "%d,%d,%d,%d", &x, &y, &w, &h); err != nil || x < 0 || y < 0 || w <= 0 || h <= 0 || x+w > maxMapSize || y+h > maxMapSize {
+				return nil, fmt.Errorf("%s:%d: spawn must be X,Y,WIDTH,HEIGHT", path, lineNum)
+			}
+			mapFile.Spawns = append(mapFile.Spawns, SpawnZone{uint8(x), uint8(y), uint8(w), uint8(h)})
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown key %q", path, lineNum, key)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s: map has no grid", path)
+	}
+	if width == 0 && height == 0 {
+		width, height = len(rows[0]), len(rows)
+	}
+	if width < minMapSize || width > maxMapSize || height < minMapSize || height > maxMapSize {
+		return nil, fmt.Errorf("%s: size must be between %d and %d", path, minMapSize, maxMapSize)
+	}
+	if len(rows) != height {
+		return nil, fmt.Errorf("%s: grid has %d rows, expected %d", path, len(rows), height)
+	}
+	mapFile.Width, mapFile.Height = uint8(width), uint8(height)
+
+	for y, row := range rows {
+		if len(row) != width {
+			return nil, fmt.Errorf("%s: grid row %d has %d cells, expected %d", path, y+1, len(row), width)
+		}
+		for x, cell := range row {
+			switch cell {
+			case '#':
+				mapFile.Walls = append(mapFile.Walls, Location{X: uint8(x), Y: uint8(y)})
+			case '$':
+				mapFile.Foods = append(mapFile.Foods, Location{X: uint8(x), Y: uint8(y)})
+			case '.':
+			default:
+				return nil, fmt.Errorf("%s: unknown cell %q at %d,%d", path, cell, x, y)
+			}
+		}
+	}
+
+	for _, zone := range mapFile.Spawns {
+		if int(zone.X)+int(zone.Width) > width || int(zone.Y)+int(zone.Height) > height {
+			return nil, fmt.Errorf("%s: spawn zone %d,%d,%d,%d is outside of the map", path, zone.X, zone.Y, zone.Width, zone.Height)
+		}
+	}
+
+	return mapFile, nil
+}
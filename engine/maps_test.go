@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadMapFile(t *testing.T) {
+	tests := []struct {
+		file string
+		want *MapFile
+		err  string // Part of the error, empty when the map is read
+	}{
+		{
+			file: "arena",
+			want: &MapFile{
+				Name:   "Arena",
+				Width:  12,
+				Height: 10,
+				Walls:  []Location{{0, 0}, {5, 4}, {11, 9}},
+				Spawns: []SpawnZone{{1, 1, 3, 3}, {8, 6, 4, 4}},
+				Foods:  []Location{{6, 5}},
+			},
+		},
+		{
+			// Named after the file, sized after the grid
+			file: "no_size",
+			want: &MapFile{Name: "no_size", Width: 10, Height: 11, Walls: []Location{{3, 3}}},
+		},
+		{file: "bad_size", err: "size must be WIDTHxHEIGHT"},
+		{file: "small", err: "size must be between"},
+		{file: "missing_rows", err: "grid has 10 rows, expected 12"},
+		{file: "row_width", err: "grid row 4 has 11 cells, expected 10"},
+		{file: "unknown_cell", err: "unknown cell 'x' at 2,3"},
+		{file: "spawn_outside", err: "spawn zone 8,8,3,3 is outside of the map"},
+		{file: "bad_spawn", err: "spawn must be X,Y,WIDTH,HEIGHT"},
+		{file: "unknown_key", err: `unknown key "walls"`},
+		{file: "no_colon", err: "expected key: value"},
+		{file: "no_grid", err: "map has no grid"},
+		{file: "missing", err: "missing.map"},
+	}
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			got, err := ReadMapFile(filepath.Join("testdata", test.file+MAP_EXTENSION))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("ReadMapFile() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ReadMapFile() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package engine
+
+import "sort"
+
+// Match phases
+const (
+	MATCH_LOBBY     uint8 = iota // Waiting for everyone to be ready
+	MATCH_COUNTDOWN              // Round starts when the countdown is over
+	MATCH_PLAYING
+	MATCH_RESULTS // Round is over, showing results before the next lobby
+)
+
+// Win conditions
+const (
+	WIN_ENDLESS    uint8 = iota // A single round lasting until the room is empty
+	WIN_POINTS                  // First snake to WinPoints points
+	WIN_TIMER                   // Highest score when MatchTime runs out
+	WIN_LAST_ALIVE              // Last snake with lives left
+	WIN_ROYALE                  // Last snake with lives left in a shrinking zone
+)
+
+const (
+	defaultWinPoints = 20
+	defaultMatchTime = 120 // Seconds
+	maxMatchTime     = 3600
+	countdownTime    = 3000 // Milliseconds
+	resultsTime      = 5000 // Milliseconds
+)
+
+type Match struct {
+	Phase    uint8
+	Round    uint16
+	TimeLeft uint32 // Milliseconds left in the phase, 0 when it has no time limit
+	Results  []Result
+}
+
+// Result of a player at the end of a round, best player first
+type Result struct {
+	UserID   uint32
+	Username string
+	Point    uint32
+	Kills    uint32
+	Deaths   uint32
+	Team     uint8
+	Winner   bool
+}
+
+// MinPlayers returns how many players are needed to start a round
+func (room *Room) MinPlayers() uint8 {
+	if room.Eliminating() {
+		return 2
+	}
+	return 1
+}
+
+// UpdateMatch moves the match through its phases, tick is the length of the
+// current tick in milliseconds
+func (room *Room) UpdateMatch(tick uint16) {
+	match := &room.match
+	if match.TimeLeft > 0 {
+		match.TimeLeft -= min(match.TimeLeft, uint32(tick))
+	}
+
+	switch match.Phase {
+	case MATCH_LOBBY:
+		if room.playerNum >= room.MinPlayers() && room.AllReady() {
+			match.Phase = MATCH_COUNTDOWN
+			match.TimeLeft = countdownTime
+		}
+	case MATCH_COUNTDOWN:
+		if room.playerNum < room.MinPlayers() || (!room.forceStart && !room.AllReady()) {
+			match.Phase = MATCH_LOBBY
+			match.TimeLeft = 0
+			room.forceStart = false
+		} else if match.TimeLeft == 0 {
+			room.forceStart = false
+			room.StartRound()
+		}
+	case MATCH_PLAYING:
+		room.UpdateZone(tick)
+		if winners, over := room.RoundOver(); over {
+			room.EndRound(winners)
+		}
+	case MATCH_RESULTS:
+		if match.TimeLeft == 0 {
+			// Players stay ready, so the next round starts on its own unless
+			// someone isn't ready anymore
+			match.Phase = MATCH_LOBBY
+			match.Results = nil
+			room.ResetZone()
+		}
+	}
+}
+
+// StartRound resets every player and puts a fresh snake for each of them on
+// a map without food
+func (room *Room) StartRound() {
+	room.ResetZone()
+	for loc := range room.foods {
+		room.SetCell(loc, CELL_EMPTY)
+		delete(room.foods, loc)
+	}
+	for _, player := range room.players {
+		room.RemoveSnake(player)
+	}
+	room.corpses = nil
+	for _, player := range room.players {
+		player.Point = 1
+		player.Kills = 0
+		player.Deaths = 0
+		player.Lives = room.settings.Lives
+		player.Spectator = false
+		player.RespawnIn = 0
+		room.SpawnSnake(player)
+	}
+
+	room.match.Phase = MATCH_PLAYING
+	room.match.Round++
+	room.match.TimeLeft = 0
+	if room.settings.WinCondition == WIN_TIMER {
+		room.match.TimeLeft = uint32(room.settings.MatchTime) * 1000
+	}
+	if room.settings.WinCondition == WIN_ROYALE {
+		room.shrinkIn = uint32(room.settings.ShrinkTime) * 1000
+	}
+}
+
+// RoundOver checks the win condition of the room, winners is empty when the
+// round ends in a draw
+func (room *Room) RoundOver() (winners []*Player, over bool) {
+	if room.BoardFull() {
+		// Nothing can spawn anymore, the best snakes win
+		if room.settings.Teams > 0 {
+			best, _ := room.BestTeams()
+			return best, true
+		}
+		return room.BestPlayers(), true
+	}
+	if room.settings.Teams > 0 {
+		return room.TeamRoundOver()
+	}
+	switch room.settings.WinCondition {
+	case WIN_POINTS:
+		best := room.BestPlayers()
+		if len(best) != 0 && best[0].Point >= uint32(room.settings.WinPoints) {
+			return best, true
+		}
+	case WIN_TIMER:
+		if room.match.TimeLeft == 0 {
+			return room.BestPlayers(), true
+		}
+	case WIN_LAST_ALIVE, WIN_ROYALE:
+		alive := []*Player{}
+		for _, player := range room.players {
+			if !player.Spectator {
+				alive = append(alive, player)
+			}
+		}
+		if len(alive) <= 1 {
+			return alive, true
+		}
+	}
+	return nil, false
+}
+
+// BestPlayers returns every player sharing the highest score
+func (room *Room) BestPlayers() []*Player {
+	best := []*Player{}
+	for _, player := range room.players {
+		if len(best) == 0 || player.Point > best[0].Point {
+			best = []*Player{player}
+		} else if player.Point == best[0].Point {
+			best = append(best, player)
+		}
+	}
+	return best
+}
+
+// EndRound freezes the room and keeps the results until the next lobby
+func (room *Room) EndRound(winners []*Player) {
+	results := []Result{}
+	for _, player := range room.players {
+		result := Result{
+			UserID:   player.UserID,
+			Username: player.Username,
+			Point:    player.Point,
+			Kills:    player.Kills,
+			Deaths:   player.Deaths,
+			Team:     player.Team,
+		}
+		for _, winner := range winners {
+			result.Winner = result.Winner || winner == player
+		}
+		results = append(results, result)
+	}
+	sort.SliceStable(results, func(i int, j int) bool {
+		if results[i].Winner != results[j].Winner {
+			return results[i].Winner
+		}
+		if results[i].Point != results[j].Point {
+			return results[i].Point > results[j].Point
+		}
+		return results[i].Username < results[j].Username
+	})
+
+	room.shrinkIn = 0
+	room.match.Phase = MATCH_RESULTS
+	room.match.TimeLeft = resultsTime
+	room.match.Results = results
+}
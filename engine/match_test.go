@@ -0,0 +1,162 @@
+package engine
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRoundOver(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings RoomSettings
+		players  []Player
+		timeLeft uint32
+		winners  []uint32 // nil when the round goes on
+	}{
+		{
+			name:     "endless",
+			settings: RoomSettings{},
+			players:  []Player{{Point: 100}},
+		},
+		{
+			name:     "points_short",
+			settings: RoomSettings{WinCondition: WIN_POINTS, WinPoints: 5},
+			players:  []Player{{Point: 4}, {Point: 1}},
+		},
+		{
+			name:     "points",
+			settings: RoomSettings{WinCondition: WIN_POINTS, WinPoints: 5},
+			players:  []Player{{Point: 4}, {Point: 6}},
+			winners:  []uint32{2},
+		},
+		{
+			name:     "points_tie",
+			settings: RoomSettings{WinCondition: WIN_POINTS, WinPoints: 5},
+			players:  []Player{{Point: 5}, {Point: 5}, {Point: 2}},
+			winners:  []uint32{1, 2},
+		},
+		{
+			name:     "timer_running",
+			settings: RoomSettings{WinCondition: WIN_TIMER},
+			players:  []Player{{Point: 4}, {Point: 6}},
+			timeLeft: 100,
+		},
+		{
+			name:     "timer_out",
+			settings: RoomSettings{WinCondition: WIN_TIMER},
+			players:  []Player{{Point: 4}, {Point: 6}},
+			winners:  []uint32{2},
+		},
+		{
+			name:     "last_alive_running",
+			settings: RoomSettings{WinCondition: WIN_LAST_ALIVE},
+			players:  []Player{{}, {}, {Spectator: true}},
+		},
+		{
+			name:     "last_alive",
+			settings: RoomSettings{WinCondition: WIN_LAST_ALIVE},
+			players:  []Player{{Spectator: true}, {}, {Spectator: true}},
+			winners:  []uint32{2},
+		},
+		{
+			name:     "last_alive_draw",
+			settings: RoomSettings{WinCondition: WIN_LAST_ALIVE},
+			players:  []Player{{Spectator: true}, {Spectator: true}},
+			winners:  []uint32{},
+		},
+		{
+			name:     "royale",
+			settings: RoomSettings{WinCondition: WIN_ROYALE},
+			players:  []Player{{}, {Spectator: true}},
+			winners:  []uint32{1},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			players := make([]*Player, len(test.players))
+			for i := range test.players {
+				players[i] = &test.players[i]
+			}
+			room := newTestRoom(test.settings, players...)
+			room.match = Match{Phase: MATCH_PLAYING, TimeLeft: test.timeLeft}
+
+			winners, over := room.RoundOver()
+			if over != (test.winners != nil) {
+				t.Fatalf("RoundOver() over = %v, want %v", over, test.winners != nil)
+			}
+			ids := []uint32{}
+			for _, winner := range winners {
+				ids = append(ids, winner.UserID)
+			}
+			slices.Sort(ids)
+			if over && !slices.Equal(ids, test.winners) {
+				t.Errorf("RoundOver() winners = %v, want %v", ids, test.winners)
+			}
+		})
+	}
+}
+
+// TestMatchLifecycle plays two rounds, going through every phase of a match
+func TestMatchLifecycle(t *testing.T) {
+	room := newTestRoom(RoomSettings{WinCondition: WIN_POINTS, WinPoints: 3})
+	alice, bob := &User{ID: 1}, &User{ID: 2}
+	room.AddPlayer(alice, "alice", 'o', 0, 0)
+	room.AddPlayer(bob, "bob", 'o', 0, 0)
+	expect := func(phase uint8, timeLeft uint32) {
+		t.Helper()
+		if room.match.Phase != phase || room.match.TimeLeft != timeLeft {
+			t.Fatalf("match is in phase %d with %d ms left, want phase %d with %d ms", room.match.Phase, room.match.TimeLeft, phase, timeLeft)
+		}
+	}
+
+	room.UpdateMatch(100)
+	expect(MATCH_LOBBY, 0)
+	room.SetReady(alice, true)
+	room.UpdateMatch(100)
+	expect(MATCH_LOBBY, 0)
+	room.SetReady(bob, true)
+	room.UpdateMatch(100)
+	expect(MATCH_COUNTDOWN, countdownTime)
+
+	// Someone unready stops the countdown
+	room.SetReady(bob, false)
+	room.UpdateMatch(100)
+	expect(MATCH_LOBBY, 0)
+	room.SetReady(bob, true)
+	room.UpdateMatch(100)
+	room.UpdateMatch(countdownTime - 100)
+	expect(MATCH_COUNTDOWN, 100)
+	room.UpdateMatch(100)
+	expect(MATCH_PLAYING, 0)
+	if room.match.Round != 1 {
+		t.Errorf("first round is round %d", room.match.Round)
+	}
+
+	room.players[2].Point = 3
+	room.players[2].Kills = 2
+	room.UpdateMatch(100)
+	expect(MATCH_RESULTS, resultsTime)
+	results := room.match.Results
+	if len(results) != 2 || results[0].UserID != 2 || !results[0].Winner || results[0].Kills != 2 || results[1].Winner {
+		t.Errorf("results = %+v, want bob winning", results)
+	}
+
+	// Ready players go on with the next round on their own
+	room.UpdateMatch(resultsTime)
+	expect(MATCH_LOBBY, 0)
+	if room.match.Results != nil {
+		t.Error("results are kept in the lobby")
+	}
+	room.UpdateMatch(100)
+	expect(MATCH_COUNTDOWN, countdownTime)
+	room.UpdateMatch(countdownTime)
+	expect(MATCH_PLAYING, 0)
+	if room.match.Round != 2 {
+		t.Errorf("second round is round %d", room.match.Round)
+	}
+	for _, player := range room.players {
+		if player.Point != 1 || player.Kills != 0 || len(player.Snake) == 0 {
+			t.Errorf("player %d starts the round with %d points, %d kills and snake %v", player.UserID, player.Point, player.Kills, player.Snake)
+		}
+	}
+}
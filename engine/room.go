@@ -0,0 +1,933 @@
+package engine
+
+import (
+	"encoding/json"
+	"log"
+	"math/rand"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type DisplayResponse struct {
+	Tick    uint32 // Tick the snapshot was taken on
+	Time    uint32 // Milliseconds of room time the snapshot was taken at
+	Players []Player
+	Foods   []Food
+	Speed   uint16 // Current tick interval in milliseconds
+	Level   uint8  // Only set on SPEED_LEVEL
+	Width   uint8
+	Height  uint8
+	Wrap    bool
+	Events  []Event // Everything that happened since the last snapshot
+	Match   Match
+	HostID  uint32
+	// Sum of the points of every team, team 1 first
+	TeamScores []uint32
+	Zone       Zone
+	ShrinkIn   uint32 // Milliseconds before the zone shrinks, 0 when it doesn't
+	Kicked     bool   // Only sent to a player kicked from the room
+	Snapshot   uint32 // Numbers the snapshots of the room, a gap is a lost snapshot
+	Pong       bool   // Only answers a ping, nothing else is set
+	PingSent   uint32 // Sent time of the ping a pong answers
+	// Only some snapshots carry the layout, the client fills in MapName,
+	// Walls and Settings from the last one it got
+	LayoutVersion uint32
+	Layout        *RoomLayout  `json:",omitempty"`
+	MapName       string       `json:"-"`
+	Walls         []Location   `json:"-"`
+	Settings      RoomSettings `json:"-"`
+}
+
+type Event struct {
+	Type     uint8
+	UserID   uint32
+	Username string
+	Cause    uint8  // Cause of death on EVENT_DEATH
+	KillerID uint32 // Set when another snake caused the death
+	Killer   string
+	Text     string // Message on EVENT_CHAT
+}
+
+// Room settings chosen by the player who creates the room
+type RoomSettings struct {
+	TickInterval uint16 // Base tick interval in milliseconds
+	SpeedMode    uint8
+	Width        uint8 // Map size in cells, ignored when Map is set
+	Height       uint8
+	Map          [12]rune // Name of the map file, empty for an empty arena
+	Wrap         bool     // Edges of the map continue on the opposite side
+	RespawnDelay uint16   // Milliseconds a dead snake waits to respawn
+	Protection   uint16   // Milliseconds of spawn protection
+	Lives        uint8    // 0 for unlimited lives
+	Teams        uint8    // Number of teams, 0 for free-for-all
+	FriendlyFire bool     // Teammates die running into each other
+	WinCondition uint8
+	WinPoints    uint16            // Points needed to win on WIN_POINTS
+	MatchTime    uint16            // Length of a round on WIN_TIMER in seconds
+	ShrinkTime   uint16            // Seconds between two shrinks of the zone on WIN_ROYALE
+	FoodWeights  [FOOD_KINDS]uint8 // Chance of every food kind to spawn
+	FoodCaps     [FOOD_KINDS]uint8 // Most food of every kind at once, 0 for no limit
+	FoodSpawner  uint8
+}
+
+type Room struct {
+	ID                     uint8
+	playerNum              uint8
+	mainChannel            chan MoveRequest
+	playerMovesMutRun      sync.Mutex //Mutex for playerMoves on Run
+	playerMovesMutMainChan sync.Mutex //Mutex for playerMoves on HandleMainChannel
+	playerMoves            map[uint32]chan MoveRequest
+	players                map[uint32]*Player
+	roomMap                [][]uint8
+	playersMut             sync.Mutex         // Mutex for players and playerNum
+	foods                  map[Location]uint8 // Kind of the food on every food cell
+	settings               RoomSettings
+	mapFile                *MapFile // nil for an empty arena
+	speed                  uint16   // Current tick interval in milliseconds
+	level                  uint8
+	snapshot               uint32 // Snapshots sent since the room was created
+	layout                 RoomLayout
+	layoutSends            uint8   // Next snapshots carrying the layout
+	sendLayout             bool    // The snapshot being sent carries the layout
+	events                 []Event // Events since the last snapshot
+	match                  Match
+	hostID                 uint32
+	forceStart             bool   // Host started the match without everyone ready
+	joinCount              uint64 // Players who ever joined, to order them
+	tick                   uint32 // Ticks run since the room was created
+	clock                  uint32 // Milliseconds of ticks run since the room was created
+	zone                   Zone
+	shrinkIn               uint32 // Milliseconds before the zone shrinks, 0 when it doesn't
+	spawner                FoodSpawner
+	corpses                [][]Location // Bodies of the snakes died since the last food spawn
+	freeCells              []Location   // Empty cells of roomMap
+	freeIndex              [][]int      // Position of every cell in freeCells, -1 when taken
+	maps                   map[string]*MapFile
+	send                   func(userID uint32, snapshot []byte) // Sends a snapshot to a player
+}
+
+type Player struct {
+	UserID     uint32
+	Move       rune
+	Snake      []Location
+	Point      uint32
+	Username   string
+	SnakeShape rune
+	Speed      uint16 // Tick interval this snake moves at
+	Kills      uint32
+	Deaths     uint32
+	RespawnIn  uint16 // Milliseconds until a dead snake respawns
+	Protected  uint16 // Milliseconds of spawn protection left
+	Boost      uint16 // Milliseconds of FOOD_SPEED left
+	Ghost      uint16 // Milliseconds of FOOD_GHOST left
+	Magnet     uint16 // Milliseconds of FOOD_MAGNET left
+	Lives      uint8  // Lives left when the room has limited lives
+	Spectator  bool   // Out of lives, only watching
+	Ready      bool
+	Bot        bool  // Moved by the room itself
+	Team       uint8 // 0 on free-for-all rooms
+	Color      uint8 // Colour of the snake in the 256 colour palette
+	joinOrder  uint64
+	InputSeq   uint32 // Seq of the last move the server took from the player
+	lastInput  rune   // Last move queued in playerMoves
+	moveWait   uint16 // Milliseconds waited since last move on SPEED_LENGTH or while boosted
+	user       *User  // nil for bots
+}
+
+// Cell types of roomMap
+const (
+	CELL_EMPTY uint8 = iota
+	CELL_SNAKE
+	CELL_FOOD
+	CELL_WALL
+)
+
+// Event types
+const (
+	EVENT_DEATH uint8 = iota
+	EVENT_FOOD
+	EVENT_JOIN
+	EVENT_LEAVE
+	EVENT_OUT  // Out of lives
+	EVENT_HOST // Player became the host
+	EVENT_CHAT
+)
+
+// Death causes
+const (
+	DEATH_WALL    uint8 = iota // Ran into a wall or off the edge of the map
+	DEATH_SELF                 // Ran into its own body
+	DEATH_SNAKE                // Ran into the body of another snake
+	DEATH_HEAD_ON              // Ran head-on into another snake
+	DEATH_ZONE                 // Caught outside of the zone on WIN_ROYALE
+)
+
+const KILL_POINTS = 5 // Points for the snake whose body killed another one
+
+// Speed modes
+const (
+	SPEED_FIXED  uint8 = iota // Always use the base tick interval
+	SPEED_SCORE               // Speed up as the total score of the room rises
+	SPEED_LEVEL               // Speed up in tiers every pointsPerLevel points
+	SPEED_LENGTH              // Every snake moves faster the longer it is
+)
+
+const (
+	defaultTickInterval = 750
+	minTickInterval     = 100
+	maxTickInterval     = 2000
+	scoreSpeedStep      = 10 // Milliseconds removed per point on SPEED_SCORE
+	lengthSpeedStep     = 25 // Milliseconds removed per segment on SPEED_LENGTH
+	pointsPerLevel      = 10
+	lengthModeTick      = 50 // Tick granularity on SPEED_LENGTH
+	defaultMapSize      = 30
+	minMapSize          = 10
+	maxMapSize          = 100
+	maxSpawnTries       = 100
+)
+
+// Tick interval of each level in percent of the base tick interval
+var levelSpeeds = []int{100, 85, 70, 60, 50, 40}
+
+// NormalizeSettings fills in defaults and keeps settings in their valid range
+func NormalizeSettings(settings RoomSettings) RoomSettings {
+	if settings.TickInterval == 0 {
+		settings.TickInterval = defaultTickInterval
+	}
+	settings.TickInterval = clampInterval(int(settings.TickInterval))
+	if settings.SpeedMode > SPEED_LENGTH {
+		settings.SpeedMode = SPEED_FIXED
+	}
+	settings.Width = clampMapSize(settings.Width)
+	settings.Height = clampMapSize(settings.Height)
+	if settings.RespawnDelay == 0 {
+		settings.RespawnDelay = defaultRespawnDelay
+	}
+	settings.RespawnDelay = min(settings.RespawnDelay, maxTimerSetting)
+	if settings.Protection == 0 {
+		settings.Protection = defaultSpawnProtection
+	}
+	settings.Protection = min(settings.Protection, maxTimerSetting)
+	settings.Lives = min(settings.Lives, maxLives)
+	if settings.WinCondition > WIN_ROYALE {
+		settings.WinCondition = WIN_ENDLESS
+	}
+	if settings.WinPoints == 0 {
+		settings.WinPoints = defaultWinPoints
+	}
+	if settings.MatchTime == 0 {
+		settings.MatchTime = defaultMatchTime
+	}
+	settings.MatchTime = min(settings.MatchTime, maxMatchTime)
+	if settings.ShrinkTime == 0 {
+		settings.ShrinkTime = defaultShrinkTime
+	}
+	settings.ShrinkTime = min(settings.ShrinkTime, maxShrinkTime)
+	if settings.FoodWeights == [FOOD_KINDS]uint8{} {
+		settings.FoodWeights = defaultFoodWeights
+	}
+	if settings.FoodCaps == [FOOD_KINDS]uint8{} {
+		settings.FoodCaps = defaultFoodCaps
+	}
+	if settings.FoodSpawner > SPAWNER_BURST {
+		settings.FoodSpawner = SPAWNER_UNIFORM
+	}
+	if settings.Teams == 1 {
+		settings.Teams = 2
+	}
+	settings.Teams = min(settings.Teams, maxTeams)
+	if (settings.WinCondition == WIN_LAST_ALIVE || settings.WinCondition == WIN_ROYALE) && settings.Lives == 0 {
+		// Every snake needs a last life to lose
+		settings.Lives = 1
+	}
+	return settings
+}
+
+func clampMapSize(size uint8) uint8 {
+	if size == 0 {
+		return defaultMapSize
+	} else if size < minMapSize {
+		return minMapSize
+	} else if size > maxMapSize {
+		return maxMapSize
+	}
+	return size
+}
+
+func clampInterval(interval int) uint16 {
+	if interval < minTickInterval {
+		return minTickInterval
+	} else if interval > maxTickInterval {
+		return maxTickInterval
+	}
+	return uint16(interval)
+}
+
+// NewRoom creates a room on one of maps, send is given the snapshots of every
+// player of the room
+func NewRoom(id uint8, settings RoomSettings, maps map[string]*MapFile, send func(userID uint32, snapshot []byte)) *Room {
+	room := &Room{
+		ID:          id,
+		mainChannel: make(chan MoveRequest, 1),
+		playerMoves: make(map[uint32]chan MoveRequest),
+		players:     make(map[uint32]*Player),
+		foods:       make(map[Location]uint8),
+		settings:    NormalizeSettings(settings),
+		maps:        maps,
+		send:        send,
+	}
+	room.InitialMap()
+	return room
+}
+
+func (room *Room) InitialMap() {
+	mapName := strings.ReplaceAll(string(room.settings.Map[:]), "\x00", "")
+	if mapFile, exist := room.maps[mapName]; exist {
+		room.mapFile = mapFile
+		room.settings.Width = mapFile.Width
+		room.settings.Height = mapFile.Height
+	} else {
+		room.settings.Map = [12]rune{}
+	}
+
+	room.roomMap = make([][]uint8, room.settings.Height)
+	for y := range room.roomMap {
+		room.roomMap[y] = make([]uint8, room.settings.Width)
+	}
+	for _, wall := range room.Walls() {
+		room.roomMap[wall.Y][wall.X] = CELL_WALL
+	}
+	room.ResetCells()
+	room.ResetZone()
+	room.spawner = NewFoodSpawner(room.settings.FoodSpawner)
+	room.corpses = nil
+	room.UpdateLayout()
+}
+
+func (room *Room) Walls() []Location {
+	if room.mapFile == nil {
+		return []Location{}
+	}
+	return room.mapFile.Walls
+}
+
+func (room *Room) MapName() string {
+	if room.mapFile == nil {
+		return ""
+	}
+	return room.mapFile.Name
+}
+
+// Start runs the room until everyone left it
+func (room *Room) Start() {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go room.HandleMainChannel(&wg)
+	wg.Add(1)
+	go room.Run(&wg)
+	wg.Wait()
+}
+
+func (room *Room) Run(wg *sync.WaitGroup) {
+	defer wg.Done()
+	for {
+		start := time.Now()
+
+		room.playersMut.Lock()
+		if room.playerNum == 0 {
+			room.playersMut.Unlock()
+			break
+		}
+		room.tick++
+		room.UpdateSpeed()
+		tick := room.TickInterval()
+		room.clock += uint32(tick)
+		room.UpdateMatch(tick)
+		playing := room.match.Phase == MATCH_PLAYING
+		timersChanged := playing && room.UpdateTimers(tick)
+		room.playersMut.Unlock()
+
+		// Move all player
+		moves := make(map[*Player]rune)
+		room.playerMovesMutRun.Lock()
+		room.playersMut.Lock()
+		for userId, moveCn := range room.playerMoves {
+			player, exist := room.players[userId]
+			if !exist {
+				continue
+			}
+			if player.Snake == nil || !playing {
+				// Forget moves made while dead or between rounds
+				for len(moveCn) != 0 {
+					player.InputSeq = (<-moveCn).Seq
+				}
+				continue
+			}
+			if !room.PlayerDue(player, tick) {
+				continue
+			}
+			moves[player] = player.Move
+			if len(moveCn) != 0 {
+				move := <-moveCn
+				moves[player] = move.Move
+				player.InputSeq = move.Seq
+			}
+		}
+		for _, player := range room.players {
+			if player.Bot && player.Snake != nil && playing && room.PlayerDue(player, tick) {
+				moves[player] = room.BotMove(player)
+			}
+		}
+		room.MovePlayers(moves)
+		room.playersMut.Unlock()
+		room.playerMovesMutRun.Unlock()
+		moved := len(moves) != 0 || timersChanged || !playing
+
+		// Spawn food
+		room.playersMut.Lock()
+		room.SpawnFoods()
+		room.playersMut.Unlock()
+
+		// Send data to client
+		if moved {
+			room.playersMut.Lock()
+			room.snapshot++
+			room.sendLayout = room.LayoutDue()
+			var wgResponse sync.WaitGroup
+			for _, player := range room.players {
+				wgResponse.Add(1)
+				go room.SendResponse(player, &wgResponse)
+			}
+			wgResponse.Wait()
+			room.events = nil
+			room.playersMut.Unlock()
+		}
+
+		// Tickrate
+		deltaTime := time.Since(start)
+		if deltaTime < time.Duration(tick)*time.Millisecond {
+			time.Sleep(time.Duration(tick)*time.Millisecond - deltaTime)
+		}
+
+	}
+}
+
+// UpdateSpeed recalculates the room and snake speeds from the speed mode
+func (room *Room) UpdateSpeed() {
+	base := int(room.settings.TickInterval)
+	totalPoint := 0
+	for _, player := range room.players {
+		totalPoint += int(player.Point) - 1
+	}
+
+	room.speed = uint16(base)
+	room.level = 0
+	switch room.settings.SpeedMode {
+	case SPEED_SCORE:
+		room.speed = clampInterval(base - totalPoint*scoreSpeedStep)
+	case SPEED_LEVEL:
+		level := totalPoint / pointsPerLevel
+		if level >= len(levelSpeeds) {
+			level = len(levelSpeeds) - 1
+		}
+		room.level = uint8(level + 1)
+		room.speed = clampInterval(base * levelSpeeds[level] / 100)
+	}
+
+	for _, player := range room.players {
+		player.Speed = room.speed
+		if room.settings.SpeedMode == SPEED_LENGTH {
+			player.Speed = clampInterval(base - (len(player.Snake)-1)*lengthSpeedStep)
+		}
+		if player.Boost > 0 {
+			player.Speed = clampInterval(int(player.Speed) / 2)
+		}
+	}
+}
+
+// PlayerSpeeds reports whether snakes move at their own speed, skipping
+// ticks until it is their turn
+func (room *Room) PlayerSpeeds() bool {
+	if room.settings.SpeedMode == SPEED_LENGTH {
+		return true
+	}
+	for _, player := range room.players {
+		if player.Boost > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// TickInterval returns how long a single tick of Run lasts in milliseconds
+func (room *Room) TickInterval() uint16 {
+	if room.PlayerSpeeds() {
+		return lengthModeTick
+	}
+	return room.speed
+}
+
+// PlayerDue reports whether the player moves on this tick. Snakes only skip
+// ticks when they move at their own speed, otherwise they move on every tick.
+func (room *Room) PlayerDue(player *Player, tick uint16) bool {
+	if !room.PlayerSpeeds() {
+		return true
+	}
+	player.moveWait += tick
+	if player.moveWait < player.Speed {
+		return false
+	}
+	player.moveWait = 0
+	return true
+}
+
+// Move queues a move sent by a player
+func (room *Room) Move(move MoveRequest) {
+	room.mainChannel <- move
+}
+
+func (room *Room) HandleMainChannel(wg *sync.WaitGroup) {
+	defer wg.Done()
+	for {
+		move := <-room.mainChannel
+		room.playersMut.Lock()
+		empty := room.playerNum == 0
+		room.playersMut.Unlock()
+		if empty {
+			break
+		}
+
+		room.playerMovesMutMainChan.Lock()
+		if moveCn, exist := room.playerMoves[move.UserID]; exist && len(moveCn) < cap(moveCn) {
+			room.playersMut.Lock()
+			if player, exist := room.players[move.UserID]; exist && room.QueueMove(player, move.Move, len(moveCn) != 0) {
+				moveCn <- move
+			}
+			room.playersMut.Unlock()
+		}
+		room.playerMovesMutMainChan.Unlock()
+	}
+}
+
+func (room *Room) AddPlayer(user *User, username string, snakeShape rune, team uint8, color uint8) bool {
+	if !ValidName(username) || !ValidShape(snakeShape) {
+		return false
+	}
+	room.playersMut.Lock()
+	defer room.playersMut.Unlock()
+
+	player := &Player{
+		UserID:     user.ID,
+		Username:   username,
+		SnakeShape: snakeShape,
+		user:       user,
+	}
+	if !room.Join(player, team, color) {
+		return false
+	}
+	user.RoomID = room.ID
+	room.playerMoves[user.ID] = make(chan MoveRequest, InputQueueSize)
+	return true
+}
+
+// Join puts a new player in the room, reporting whether there was room for
+// them. playersMut must be held.
+func (room *Room) Join(player *Player, team uint8, color uint8) bool {
+	if room.playerNum > 4 {
+		return false
+	}
+	// Cari koordinat pertama
+	player.joinOrder = room.joinCount + 1
+	player.Point = 1
+	player.Speed = room.speed
+	player.Lives = room.settings.Lives
+	room.JoinTeam(player, team)
+	room.PickColor(player, color)
+	if room.Eliminating() && room.match.Phase == MATCH_PLAYING {
+		// Joining a running round of last snake alive is only watching
+		player.Spectator = true
+	} else if !room.SpawnSnake(player) {
+		// No empty cell left for a new snake
+		return false
+	}
+	room.joinCount++
+	room.playerNum++
+	room.players[player.UserID] = player
+	if len(room.players) == 1 {
+		// Whoever creates the room hosts it
+		room.SetHost(player)
+	}
+	room.events = append(room.events, Event{Type: EVENT_JOIN, UserID: player.UserID, Username: player.Username})
+	// The new player needs the layout
+	room.layoutSends = layoutRepeats
+	return true
+}
+
+// ExitRoom takes a user out of the room, reporting whether they were in it
+func (room *Room) ExitRoom(user *User) bool {
+	// Lock for run
+	room.playerMovesMutRun.Lock()
+	// Lock for handle main channel
+	room.playerMovesMutMainChan.Lock()
+
+	delete(room.playerMoves, user.ID)
+	room.playersMut.Lock()
+	_, exist := room.players[user.ID]
+	room.Leave(user.ID)
+	if len(room.playerMoves) == 0 {
+		// Bots don't keep a room nobody plays in
+		for id, player := range room.players {
+			if player.Bot {
+				room.Leave(id)
+			}
+		}
+	}
+	room.playersMut.Unlock()
+	// Make sure HandleMainChannel for loop break
+	room.mainChannel <- MoveRequest{UserID: user.ID, Move: 'e'}
+
+	// A user who already left may be in another room now
+	if exist && user.RoomID == room.ID {
+		user.RoomID = 0
+	}
+
+	room.playerMovesMutMainChan.Unlock()
+	room.playerMovesMutRun.Unlock()
+	return exist
+}
+
+// Leave takes a player out of the room, playersMut must be held
+func (room *Room) Leave(id uint32) {
+	player, exist := room.players[id]
+	if !exist {
+		return
+	}
+	room.RemoveSnake(player)
+	room.events = append(room.events, Event{Type: EVENT_LEAVE, UserID: id, Username: player.Username})
+	delete(room.players, id)
+	room.playerNum--
+	if room.hostID == id {
+		room.PickHost()
+	}
+}
+
+// FindSpawnLoc finds an empty cell for a snake inside the spawn zones of the
+// map, falling back to any empty cell when the zones are full
+func (room *Room) FindSpawnLoc() (Location, bool) {
+	if room.mapFile == nil || len(room.mapFile.Spawns) == 0 {
+		return room.FindLoc()
+	}
+	for try := 0; try < maxSpawnTries; try++ {
+		zone := room.mapFile.Spawns[rand.Intn(len(room.mapFile.Spawns))]
+		x := zone.X + uint8(rand.Intn(int(zone.Width)))
+		y := zone.Y + uint8(rand.Intn(int(zone.Height)))
+		if room.roomMap[y][x] == CELL_EMPTY && room.InZone(Location{X: x, Y: y}) {
+			return Location{X: x, Y: y}, true
+		}
+	}
+	return room.FindLoc()
+}
+
+// QueueMove reports whether a move is worth queueing. Moves repeating or
+// reversing the direction the snake will have once the moves already queued
+// are done are dropped.
+func (room *Room) QueueMove(player *Player, move rune, queued bool) bool {
+	last := player.Move
+	if queued {
+		last = player.lastInput
+	}
+	if !Accepts(last, move) {
+		return false
+	}
+	player.lastInput = move
+	return true
+}
+
+// Turn changes the direction of the snake unless it reverses onto itself
+func (room *Room) Turn(player *Player, move rune) {
+	player.Move = Turn(player.Move, move)
+}
+
+// Movement of a single snake on a tick
+type moveIntent struct {
+	player *Player
+	next   Location // Cell the head moves into
+	eats   bool     // New head is on food
+	grows  bool
+	dies   bool
+	cause  uint8
+	killer *Player
+}
+
+// MovePlayers moves every snake in moves at the same time, so the outcome
+// never depends on the order players are stored in. The rules of a tick:
+//
+//   - A snake moving off the edge of a non wrapping map or into a wall dies.
+//   - A snake grows when its new head is on food other than poison, a
+//     growing snake keeps its tail. Poison cuts the tail once it moved.
+//   - Tails left on this tick are free, a snake may follow any tail closely,
+//     including its own, unless the owner of that tail grows on this tick.
+//   - Heads moving into the same cell or swapping cells collide head-on and
+//     all of them die. Food on such a cell is not eaten.
+//   - A head moving into a cell taken by the body of any snake after this
+//     tick's movement dies. Snakes dying on this tick still block the others,
+//     as do snakes not moving on this tick.
+//   - The owner of the body another snake runs into gets KILL_POINTS, even
+//     when it dies on the same tick. Head-on collisions award nobody.
+//   - Snakes under spawn protection pass through other snakes and other
+//     snakes pass through them, only walls and edges kill them.
+//   - Teammates pass through each other unless friendly fire is on, killing
+//     a teammate awards no points.
+//   - Ghost snakes pass through every body, including their own, but still
+//     collide head-on.
+//
+// Dead snakes respawn once their RespawnIn countdown is over.
+func (room *Room) MovePlayers(moves map[*Player]rune) {
+	intents := make([]*moveIntent, 0, len(moves))
+	for player, move := range moves {
+		room.Turn(player, move)
+		intent := &moveIntent{player: player}
+		next, ok := room.NextLoc(player.Snake[0], player.Move)
+		if !ok || room.roomMap[next.Y][next.X] == CELL_WALL {
+			intent.dies = true
+			intent.cause = DEATH_WALL
+		} else if !room.InZone(next) {
+			intent.dies = true
+			intent.cause = DEATH_ZONE
+		} else {
+			intent.next = next
+			intent.eats = room.roomMap[next.Y][next.X] == CELL_FOOD
+			intent.grows = intent.eats && room.foods[next] != FOOD_POISON
+		}
+		intents = append(intents, intent)
+	}
+
+	// Owners of the cells taken by bodies once every snake has moved,
+	// teammates passing through each other may share a cell
+	bodies := make(map[Location][]*Player)
+	for _, player := range room.players {
+		body := player.Snake
+		if player.Protected > 0 {
+			continue
+		}
+		if _, moving := moves[player]; moving {
+			body = body[:len(body)-1]
+		}
+		for _, loc := range body {
+			bodies[loc] = append(bodies[loc], player)
+		}
+	}
+	for _, intent := range intents {
+		if intent.grows && intent.player.Protected == 0 {
+			tail := intent.player.Snake[len(intent.player.Snake)-1]
+			bodies[tail] = append(bodies[tail], intent.player)
+		}
+	}
+
+	// Only mark collisions once every snake is checked, so a snake dying
+	// before the others are checked can't change what happens to them
+	collided := []*moveIntent{}
+	for _, intent := range intents {
+		if intent.dies || intent.player.Protected > 0 {
+			continue
+		}
+		hit := false
+		for _, owner := range bodies[intent.next] {
+			if intent.player.Ghost > 0 {
+				break
+			}
+			if owner == intent.player {
+				hit = true
+				intent.cause, intent.killer = DEATH_SELF, nil
+				break
+			} else if !room.PassThrough(intent.player, owner) {
+				hit = true
+				intent.cause, intent.killer = DEATH_SNAKE, owner
+			}
+		}
+		// Head-on collisions take precedence over running into a body
+		for _, other := range intents {
+			sameCell := other.next == intent.next
+			swapped := other.next == intent.player.Snake[0] && intent.next == other.player.Snake[0]
+			if other == intent || other.dies || other.player.Protected > 0 || room.PassThrough(intent.player, other.player) {
+				continue
+			}
+			if sameCell || swapped {
+				hit = true
+				intent.cause, intent.killer = DEATH_HEAD_ON, other.player
+			}
+		}
+		if hit {
+			collided = append(collided, intent)
+		}
+	}
+	for _, intent := range collided {
+		intent.dies = true
+	}
+
+	for _, intent := range intents {
+		if intent.dies {
+			room.KillPlayer(intent.player, intent.cause, intent.killer)
+		} else if !intent.grows {
+			tail := intent.player.Snake[len(intent.player.Snake)-1]
+			room.SetCell(tail, CELL_EMPTY)
+		}
+	}
+	for _, intent := range intents {
+		if intent.dies {
+			continue
+		}
+		player := intent.player
+		player.Snake = Advance(player.Snake, intent.next, intent.grows)
+		room.SetCell(intent.next, CELL_SNAKE)
+		if kind, exist := room.foods[intent.next]; intent.eats && exist {
+			delete(room.foods, intent.next)
+			room.Eat(player, kind)
+			room.events = append(room.events, Event{Type: EVENT_FOOD, UserID: player.UserID, Username: player.Username})
+		}
+	}
+
+	// Protected snakes may share cells with other snakes, so a tail cleared
+	// above might still be taken by another snake
+	for _, player := range room.players {
+		for _, loc := range player.Snake {
+			room.SetCell(loc, CELL_SNAKE)
+		}
+	}
+	// Only cells no snake is on are free for pulled food
+	for _, intent := range intents {
+		if !intent.dies && intent.player.Magnet > 0 {
+			room.PullFood(intent.player)
+		}
+	}
+}
+
+// NextLoc returns the cell in front of loc. On wrapping rooms the edges of the
+// map continue on the opposite side, otherwise ok is false when loc is on the
+// edge the move heads to.
+func (room *Room) NextLoc(loc Location, move rune) (next Location, ok bool) {
+	return NextLoc(loc, move, room.settings.Width, room.settings.Height, room.settings.Wrap)
+}
+
+// KillPlayer removes the snake of a dead player and tells everyone who killed
+// it. killer is nil unless another snake caused the death.
+func (room *Room) KillPlayer(player *Player, cause uint8, killer *Player) {
+	event := Event{Type: EVENT_DEATH, UserID: player.UserID, Username: player.Username, Cause: cause}
+	if killer != nil {
+		event.KillerID = killer.UserID
+		event.Killer = killer.Username
+		if cause == DEATH_SNAKE && !room.SameTeam(player, killer) {
+			killer.Kills++
+			killer.Point += KILL_POINTS
+		}
+	}
+	room.events = append(room.events, event)
+	player.Deaths++
+	room.corpses = append(room.corpses, player.Snake)
+	room.RemoveSnake(player)
+
+	player.RespawnIn = room.settings.RespawnDelay
+	if room.settings.Lives > 0 {
+		player.Lives--
+		if player.Lives == 0 {
+			player.Spectator = true
+			room.events = append(room.events, Event{Type: EVENT_OUT, UserID: player.UserID, Username: player.Username})
+		}
+	}
+}
+
+// RemoveSnake clears the snake from the map, keeping the cells other snakes
+// are still on
+func (room *Room) RemoveSnake(player *Player) {
+	body := player.Snake
+	player.Snake = nil
+	for _, snakeLoc := range body {
+		room.LeaveCell(snakeLoc)
+	}
+	player.Boost = 0
+	player.Ghost = 0
+	player.Magnet = 0
+}
+
+// LeaveCell empties a cell a snake left, unless a snake is still on it.
+// Teammates, protected and ghost snakes may share cells.
+func (room *Room) LeaveCell(loc Location) {
+	for _, player := range room.players {
+		if slices.Contains(player.Snake, loc) {
+			return
+		}
+	}
+	room.SetCell(loc, CELL_EMPTY)
+}
+
+// Respawn puts a removed snake back on the map as a new one cell snake
+func (room *Room) Respawn(player *Player) {
+	// Scores only last until death when the room has no rounds
+	if room.settings.WinCondition == WIN_ENDLESS {
+		player.Point = 1
+	}
+	room.SpawnSnake(player)
+}
+
+func (room *Room) SendResponse(player *Player, wg *sync.WaitGroup) {
+	defer wg.Done()
+	foods := []Food{}
+	for foodLoc, kind := range room.foods {
+		foods = append(foods, Food{foodLoc, kind})
+	}
+	players := []Player{}
+	for _, player := range room.players {
+		players = append(players, *player)
+	}
+
+	response := DisplayResponse{
+		Players:       players,
+		Foods:         foods,
+		Speed:         room.speed,
+		Level:         room.level,
+		Width:         room.settings.Width,
+		Height:        room.settings.Height,
+		Wrap:          room.settings.Wrap,
+		LayoutVersion: room.layout.Version,
+		Events:        room.events,
+		Match:         room.match,
+		HostID:        room.hostID,
+		Tick:          room.tick,
+		Time:          room.clock,
+		Snapshot:      room.snapshot,
+		TeamScores:    room.TeamScores(),
+		Zone:          room.zone,
+		ShrinkIn:      room.shrinkIn,
+	}
+	if room.sendLayout {
+		response.Layout = &room.layout
+	}
+	// fmt.Println(len(room.foods))
+	if !player.Bot {
+		room.send(player.UserID, room.EncodeDisplayResponse(response))
+	}
+}
+
+// SendKicked tells a player removed by the host that they left the room
+func (room *Room) SendKicked(user *User) {
+	room.send(user.ID, room.EncodeDisplayResponse(DisplayResponse{Kicked: true}))
+}
+
+// Pong returns the snapshot answering a ping
+func Pong(ping MoveRequest) []byte {
+	jsonResponse, err := json.Marshal(DisplayResponse{Pong: true, PingSent: ping.Sent})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return jsonResponse
+}
+
+func (room *Room) EncodeDisplayResponse(response DisplayResponse) []byte {
+	jsonResponse, err := json.Marshal(response)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return jsonResponse
+}
@@ -0,0 +1,446 @@
+package engine
+
+import (
+	"slices"
+	"sync"
+	"testing"
+)
+
+// newTestRoom creates a 10x10 room holding players, numbered from 1 in the
+// order given, with their snakes already on the map
+func newTestRoom(settings RoomSettings, players ...*Player) *Room {
+	settings.Width, settings.Height = 10, 10
+	room := NewRoom(1, settings, nil, func(uint32, []byte) {})
+	for i, player := range players {
+		player.UserID = uint32(i + 1)
+		player.Point = max(player.Point, 1)
+		room.players[player.UserID] = player
+		room.playerNum++
+		for _, loc := range player.Snake {
+			room.SetCell(loc, CELL_SNAKE)
+		}
+	}
+	return room
+}
+
+// addFood puts a food of the given kind on loc
+func addFood(room *Room, loc Location, kind uint8) {
+	room.foods[loc] = kind
+	room.SetCell(loc, CELL_FOOD)
+}
+
+func TestMovePlayers(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings RoomSettings
+		players  []Player
+		moves    map[int]rune // Players moving on the tick by index, the others stand still
+		walls    []Location
+		foods    map[Location]uint8
+		deaths   map[int]uint8      // Cause of death of every player dying
+		snakes   map[int][]Location // Snakes of the survivors after the tick
+		points   []uint32
+	}{
+		{
+			name:    "edge",
+			players: []Player{{Snake: []Location{{0, 5}, {1, 5}}}},
+			moves:   map[int]rune{0: '<'},
+			deaths:  map[int]uint8{0: DEATH_WALL},
+			points:  []uint32{1},
+		},
+		{
+			name:    "wall",
+			players: []Player{{Snake: []Location{{4, 5}}}},
+			moves:   map[int]rune{0: '>'},
+			walls:   []Location{{5, 5}},
+			deaths:  map[int]uint8{0: DEATH_WALL},
+			points:  []uint32{1},
+		},
+		{
+			name:    "grow",
+			players: []Player{{Snake: []Location{{2, 2}, {1, 2}}}},
+			moves:   map[int]rune{0: '>'},
+			foods:   map[Location]uint8{{3, 2}: FOOD_NORMAL},
+			snakes:  map[int][]Location{0: {{3, 2}, {2, 2}, {1, 2}}},
+			points:  []uint32{2},
+		},
+		{
+			name:    "poison",
+			players: []Player{{Snake: []Location{{4, 2}, {3, 2}, {2, 2}, {1, 2}, {0, 2}}, Point: 4}},
+			moves:   map[int]rune{0: '>'},
+			foods:   map[Location]uint8{{5, 2}: FOOD_POISON},
+			snakes:  map[int][]Location{0: {{5, 2}, {4, 2}}},
+			points:  []uint32{1},
+		},
+		{
+			name: "follow_tail",
+			players: []Player{
+				{Snake: []Location{{2, 2}, {1, 2}}},
+				{Snake: []Location{{4, 3}, {4, 2}, {3, 2}}},
+			},
+			moves:  map[int]rune{0: '>', 1: 'v'},
+			snakes: map[int][]Location{0: {{3, 2}, {2, 2}}, 1: {{4, 4}, {4, 3}, {4, 2}}},
+			points: []uint32{1, 1},
+		},
+		{
+			name:    "follow_own_tail",
+			players: []Player{{Snake: []Location{{1, 1}, {2, 1}, {2, 2}, {1, 2}}}},
+			moves:   map[int]rune{0: 'v'},
+			snakes:  map[int][]Location{0: {{1, 2}, {1, 1}, {2, 1}, {2, 2}}},
+			points:  []uint32{1},
+		},
+		{
+			name: "growing_tail",
+			players: []Player{
+				{Snake: []Location{{2, 2}, {1, 2}}},
+				{Snake: []Location{{4, 3}, {4, 2}, {3, 2}}},
+			},
+			moves:  map[int]rune{0: '>', 1: 'v'},
+			foods:  map[Location]uint8{{4, 4}: FOOD_NORMAL},
+			deaths: map[int]uint8{0: DEATH_SNAKE},
+			snakes: map[int][]Location{1: {{4, 4}, {4, 3}, {4, 2}, {3, 2}}},
+			points: []uint32{1, 2 + KILL_POINTS},
+		},
+		{
+			name: "head_on",
+			players: []Player{
+				{Snake: []Location{{2, 2}}},
+				{Snake: []Location{{4, 2}}},
+			},
+			moves:  map[int]rune{0: '>', 1: '<'},
+			foods:  map[Location]uint8{{3, 2}: FOOD_NORMAL},
+			deaths: map[int]uint8{0: DEATH_HEAD_ON, 1: DEATH_HEAD_ON},
+			points: []uint32{1, 1},
+		},
+		{
+			name: "swap",
+			players: []Player{
+				{Snake: []Location{{2, 2}, {1, 2}}},
+				{Snake: []Location{{3, 2}, {4, 2}}},
+			},
+			moves:  map[int]rune{0: '>', 1: '<'},
+			deaths: map[int]uint8{0: DEATH_HEAD_ON, 1: DEATH_HEAD_ON},
+			points: []uint32{1, 1},
+		},
+		{
+			name: "body",
+			players: []Player{
+				{Snake: []Location{{2, 2}}},
+				{Snake: []Location{{2, 3}, {3, 3}}},
+			},
+			moves:  map[int]rune{0: 'v'},
+			deaths: map[int]uint8{0: DEATH_SNAKE},
+			snakes: map[int][]Location{1: {{2, 3}, {3, 3}}},
+			points: []uint32{1, 1 + KILL_POINTS},
+		},
+		{
+			name: "dying_body",
+			players: []Player{
+				{Snake: []Location{{2, 1}}},
+				{Snake: []Location{{3, 0}, {3, 1}, {3, 2}}},
+			},
+			moves:  map[int]rune{0: '>', 1: '^'},
+			deaths: map[int]uint8{0: DEATH_SNAKE, 1: DEATH_WALL},
+			points: []uint32{1, 1 + KILL_POINTS},
+		},
+		{
+			name: "protected",
+			players: []Player{
+				{Snake: []Location{{2, 2}}, Protected: 1000},
+				{Snake: []Location{{3, 2}, {3, 3}}},
+				{Snake: []Location{{5, 5}}},
+				{Snake: []Location{{6, 5}, {6, 6}}, Protected: 1000},
+			},
+			moves:  map[int]rune{0: '>', 2: '>'},
+			snakes: map[int][]Location{0: {{3, 2}}, 1: {{3, 2}, {3, 3}}, 2: {{6, 5}}, 3: {{6, 5}, {6, 6}}},
+			points: []uint32{1, 1, 1, 1},
+		},
+		{
+			name:     "teammates",
+			settings: RoomSettings{Teams: 2},
+			players: []Player{
+				{Snake: []Location{{2, 2}}, Team: 1},
+				{Snake: []Location{{3, 2}, {3, 3}}, Team: 1},
+			},
+			moves:  map[int]rune{0: '>'},
+			snakes: map[int][]Location{0: {{3, 2}}, 1: {{3, 2}, {3, 3}}},
+			points: []uint32{1, 1},
+		},
+		{
+			name:     "friendly_fire",
+			settings: RoomSettings{Teams: 2, FriendlyFire: true},
+			players: []Player{
+				{Snake: []Location{{2, 2}}, Team: 1},
+				{Snake: []Location{{3, 2}, {3, 3}}, Team: 1},
+			},
+			moves:  map[int]rune{0: '>'},
+			deaths: map[int]uint8{0: DEATH_SNAKE},
+			points: []uint32{1, 1},
+		},
+		{
+			name: "ghost",
+			players: []Player{
+				{Snake: []Location{{2, 2}}, Ghost: 1000},
+				{Snake: []Location{{3, 2}, {3, 3}}},
+			},
+			moves:  map[int]rune{0: '>'},
+			snakes: map[int][]Location{0: {{3, 2}}, 1: {{3, 2}, {3, 3}}},
+			points: []uint32{1, 1},
+		},
+		{
+			name:    "ghost_own_body",
+			players: []Player{{Snake: []Location{{1, 1}, {2, 1}, {2, 2}, {1, 2}, {0, 2}}, Ghost: 1000}},
+			moves:   map[int]rune{0: 'v'},
+			snakes:  map[int][]Location{0: {{1, 2}, {1, 1}, {2, 1}, {2, 2}, {1, 2}}},
+			points:  []uint32{1},
+		},
+		{
+			name: "ghost_head_on",
+			players: []Player{
+				{Snake: []Location{{2, 2}}, Ghost: 1000},
+				{Snake: []Location{{4, 2}}},
+			},
+			moves:  map[int]rune{0: '>', 1: '<'},
+			deaths: map[int]uint8{0: DEATH_HEAD_ON, 1: DEATH_HEAD_ON},
+			points: []uint32{1, 1},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			players := make([]*Player, len(test.players))
+			for i := range test.players {
+				players[i] = &test.players[i]
+			}
+			room := newTestRoom(test.settings, players...)
+			for _, wall := range test.walls {
+				room.SetCell(wall, CELL_WALL)
+			}
+			for loc, kind := range test.foods {
+				addFood(room, loc, kind)
+			}
+			moves := make(map[*Player]rune)
+			for i, move := range test.moves {
+				moves[players[i]] = move
+			}
+			room.MovePlayers(moves)
+
+			deaths := make(map[int]uint8)
+			for _, event := range room.events {
+				if event.Type == EVENT_DEATH {
+					deaths[int(event.UserID)-1] = event.Cause
+				}
+			}
+			if len(deaths) != len(test.deaths) {
+				t.Errorf("deaths %v, want %v", deaths, test.deaths)
+			}
+			for i, cause := range test.deaths {
+				if got, dead := deaths[i]; !dead || got != cause {
+					t.Errorf("player %d died of %d (%v), want %d", i, got, dead, cause)
+				}
+			}
+			for i, snake := range test.snakes {
+				if !slices.Equal(players[i].Snake, snake) {
+					t.Errorf("player %d snake %v, want %v", i, players[i].Snake, snake)
+				}
+			}
+			for i, point := range test.points {
+				if players[i].Point != point {
+					t.Errorf("player %d has %d points, want %d", i, players[i].Point, point)
+				}
+			}
+		})
+	}
+}
+
+// checkCells fails the test when the map and its index of empty cells don't
+// agree with the snakes and foods of the room
+func checkCells(t *testing.T, room *Room) {
+	t.Helper()
+	want := make(map[Location]uint8)
+	for _, player := range room.players {
+		for _, loc := range player.Snake {
+			want[loc] = CELL_SNAKE
+		}
+	}
+	for loc := range room.foods {
+		if want[loc] == CELL_SNAKE {
+			t.Errorf("food on snake cell %v", loc)
+		}
+		want[loc] = CELL_FOOD
+	}
+	free := 0
+	for y, row := range room.roomMap {
+		for x, cell := range row {
+			loc := Location{uint8(x), uint8(y)}
+			if cell != CELL_WALL && cell != want[loc] {
+				t.Errorf("cell %v is %d, want %d", loc, cell, want[loc])
+			}
+			i := room.freeIndex[y][x]
+			if cell != CELL_EMPTY {
+				if i != -1 {
+					t.Errorf("taken cell %v has free index %d", loc, i)
+				}
+				continue
+			}
+			free++
+			if i < 0 || i >= len(room.freeCells) || room.freeCells[i] != loc {
+				t.Errorf("empty cell %v has free index %d", loc, i)
+			}
+		}
+	}
+	if len(room.freeCells) != free {
+		t.Errorf("%d free cells, want %d", len(room.freeCells), free)
+	}
+}
+
+func TestRemoveSnake(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings RoomSettings
+		players  []Player
+		remove   func(t *testing.T, room *Room, players []*Player)
+	}{
+		{
+			name:     "zone",
+			settings: RoomSettings{WinCondition: WIN_ROYALE},
+			players: []Player{
+				{Snake: []Location{{0, 5}, {1, 5}, {2, 5}}},
+				{Snake: []Location{{2, 5}, {2, 6}}, Protected: 1000},
+				{Snake: []Location{{5, 5}}},
+			},
+			remove: func(t *testing.T, room *Room, players []*Player) {
+				room.match.Phase = MATCH_PLAYING
+				room.shrinkIn = 1
+				room.UpdateMatch(750)
+				if players[0].Snake != nil {
+					t.Error("snake outside of the zone survived")
+				}
+			},
+		},
+		{
+			name:     "leave",
+			settings: RoomSettings{Teams: 2},
+			players: []Player{
+				{Snake: []Location{{3, 2}, {3, 3}}, Team: 1},
+				{Snake: []Location{{4, 2}, {3, 2}}, Team: 1},
+			},
+			remove: func(t *testing.T, room *Room, players []*Player) {
+				room.Leave(players[1].UserID)
+			},
+		},
+		{
+			name: "exit",
+			players: []Player{
+				{Snake: []Location{{3, 4}, {3, 3}}, Ghost: 1000},
+				{Snake: []Location{{3, 5}, {3, 4}}},
+			},
+			remove: func(t *testing.T, room *Room, players []*Player) {
+				user := &User{ID: players[0].UserID, RoomID: room.ID}
+				players[0].user = user
+				room.playerMoves[user.ID] = make(chan MoveRequest, InputQueueSize)
+				room.ExitRoom(user)
+			},
+		},
+		{
+			name: "kick",
+			players: []Player{
+				{Snake: []Location{{6, 6}, {6, 7}}},
+				{Snake: []Location{{6, 7}, {6, 8}}, Bot: true, Protected: 1000},
+			},
+			remove: func(t *testing.T, room *Room, players []*Player) {
+				room.hostID = players[0].UserID
+				if !room.Kick(&User{ID: players[0].UserID, RoomID: room.ID}, players[1].UserID) {
+					t.Error("host couldn't kick the bot")
+				}
+			},
+		},
+		{
+			name: "poison",
+			players: []Player{
+				{Snake: []Location{{1, 1}, {2, 1}, {3, 1}, {4, 1}}},
+				{Snake: []Location{{4, 1}}, Protected: 1000},
+			},
+			remove: func(t *testing.T, room *Room, players []*Player) {
+				room.Shrink(players[0], 2)
+			},
+		},
+		{
+			name: "new_round",
+			players: []Player{
+				{Snake: []Location{{1, 1}, {2, 1}}},
+				{Snake: []Location{{2, 1}, {3, 1}}, Protected: 1000},
+			},
+			remove: func(t *testing.T, room *Room, players []*Player) {
+				room.StartRound()
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			players := make([]*Player, len(test.players))
+			for i := range test.players {
+				players[i] = &test.players[i]
+			}
+			room := newTestRoom(test.settings, players...)
+			test.remove(t, room, players)
+			checkCells(t, room)
+		})
+	}
+}
+
+func TestQueueMove(t *testing.T) {
+	tests := []struct {
+		name   string
+		queued rune // Last move queued, 0 when none is
+		move   rune
+		want   bool
+	}{
+		{"turn", 0, '^', true},
+		{"same", 0, '>', false},
+		{"reverse", 0, '<', false},
+		{"turn_queued", '^', '<', true},
+		{"same_queued", '^', '^', false},
+		{"reverse_queued", '^', 'v', false},
+		{"reverse_current_queued", 'v', '<', true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			player := &Player{Move: '>', lastInput: test.queued}
+			room := newTestRoom(RoomSettings{}, player)
+			if got := room.QueueMove(player, test.move, test.queued != 0); got != test.want {
+				t.Errorf("QueueMove(%c) = %v, want %v", test.move, got, test.want)
+			}
+		})
+	}
+}
+
+func TestInputQueue(t *testing.T) {
+	room := newTestRoom(RoomSettings{})
+	user := &User{ID: 1}
+	room.AddPlayer(user, "snake", 'o', 0, 0)
+	room.players[user.ID].Move = '>'
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go room.HandleMainChannel(&wg)
+
+	for i, move := range []rune{'^', '^', 'v', '<', 'v', '>'} {
+		room.Move(MoveRequest{UserID: user.ID, Move: move, Seq: uint32(i + 1)})
+	}
+	// The main channel holds a single move, once the second move of nobody
+	// is sent the first one was taken after every move above
+	room.Move(MoveRequest{})
+	room.Move(MoveRequest{})
+
+	queue := room.playerMoves[user.ID]
+	got := []MoveRequest{}
+	for len(queue) != 0 {
+		got = append(got, <-queue)
+	}
+	want := []MoveRequest{{UserID: 1, Move: '^', Seq: 1}, {UserID: 1, Move: '<', Seq: 4}, {UserID: 1, Move: 'v', Seq: 5}}
+	if !slices.Equal(got, want) {
+		t.Errorf("queued %v, want %v", got, want)
+	}
+
+	room.ExitRoom(user)
+	wg.Wait()
+}
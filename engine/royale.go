@@ -0,0 +1,71 @@
+package engine
+
+const (
+	defaultShrinkTime = 10 // Seconds between two shrinks of the zone
+	maxShrinkTime     = 600
+	minZoneSize       = 6 // The zone stops shrinking at this size
+)
+
+// Zone is the playable part of the map on WIN_ROYALE, cells outside of it are
+// lethal. On every other win condition it covers the whole map.
+type Zone struct {
+	X      uint8
+	Y      uint8
+	Width  uint8
+	Height uint8
+}
+
+// Eliminating reports whether snakes out of lives stay out until the round
+// is over, the last snake or team standing winning it
+func (room *Room) Eliminating() bool {
+	return room.settings.WinCondition == WIN_LAST_ALIVE || room.settings.WinCondition == WIN_ROYALE
+}
+
+func (room *Room) InZone(loc Location) bool {
+	zone := room.zone
+	return loc.X >= zone.X && loc.X < zone.X+zone.Width && loc.Y >= zone.Y && loc.Y < zone.Y+zone.Height
+}
+
+// ResetZone makes the whole map playable and stops the shrink timer
+func (room *Room) ResetZone() {
+	room.zone = Zone{Width: room.settings.Width, Height: room.settings.Height}
+	room.shrinkIn = 0
+}
+
+// UpdateZone counts down to the next shrink by tick milliseconds and shrinks
+// the zone when the countdown is over
+func (room *Room) UpdateZone(tick uint16) {
+	if room.shrinkIn == 0 {
+		return
+	}
+	room.shrinkIn -= min(room.shrinkIn, uint32(tick))
+	if room.shrinkIn > 0 {
+		return
+	}
+
+	zone := &room.zone
+	if zone.Width > minZoneSize {
+		zone.X++
+		zone.Width -= min(zone.Width-minZoneSize, 2)
+	}
+	if zone.Height > minZoneSize {
+		zone.Y++
+		zone.Height -= min(zone.Height-minZoneSize, 2)
+	}
+	if zone.Width > minZoneSize || zone.Height > minZoneSize {
+		room.shrinkIn = uint32(room.settings.ShrinkTime) * 1000
+	}
+
+	// Food left outside goes away and snakes caught outside die
+	for loc := range room.foods {
+		if !room.InZone(loc) {
+			room.SetCell(loc, CELL_EMPTY)
+			delete(room.foods, loc)
+		}
+	}
+	for _, player := range room.players {
+		if len(player.Snake) != 0 && !room.InZone(player.Snake[0]) {
+			room.KillPlayer(player, DEATH_ZONE, nil)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package engine
+
+import "math/rand"
+
+const (
+	defaultRespawnDelay    = 2000
+	defaultSpawnProtection = 2000
+	maxTimerSetting        = 10000
+	maxLives               = 99
+	spawnRunway            = 5 // Empty cells needed in front of a new snake
+	spawnHeadDistance      = 3 // Min distance from a new snake to other heads
+)
+
+var directions = []rune{'>', '<', '^', 'v'}
+
+// SpawnSnake puts a one cell snake on a safe cell and protects it for a while,
+// reporting whether there was any empty cell for it
+func (room *Room) SpawnSnake(player *Player) bool {
+	headLoc, move, ok := room.FindSafeSpawn()
+	if !ok {
+		return false
+	}
+	player.Snake = []Location{headLoc}
+	player.Move = move
+	player.Protected = room.settings.Protection
+	room.SetCell(headLoc, CELL_SNAKE)
+	return true
+}
+
+// FindSafeSpawn looks for a spawn cell away from other heads with a clear
+// runway of spawnRunway cells, returning the direction of the runway. It falls
+// back to any spawn cell when no safe one is found, ok is false when there is
+// no empty cell at all.
+func (room *Room) FindSafeSpawn() (Location, rune, bool) {
+	for try := 0; try < maxSpawnTries; try++ {
+		loc, ok := room.FindSpawnLoc()
+		if !ok {
+			return loc, '>', false
+		}
+		if room.NearHead(loc) {
+			continue
+		}
+		for _, i := range rand.Perm(len(directions)) {
+			if room.HasRunway(loc, directions[i]) {
+				return loc, directions[i], true
+			}
+		}
+	}
+	loc, ok := room.FindSpawnLoc()
+	return loc, '>', ok
+}
+
+// HasRunway reports whether a snake on loc can move spawnRunway cells
+// towards move without running into anything
+func (room *Room) HasRunway(loc Location, move rune) bool {
+	for i := 0; i < spawnRunway; i++ {
+		next, ok := room.NextLoc(loc, move)
+		if !ok || !room.InZone(next) {
+			return false
+		}
+		cell := room.roomMap[next.Y][next.X]
+		if cell != CELL_EMPTY && cell != CELL_FOOD {
+			return false
+		}
+		loc = next
+	}
+	return true
+}
+
+// NearHead reports whether the head of any snake is within spawnHeadDistance
+// cells of loc
+func (room *Room) NearHead(loc Location) bool {
+	for _, player := range room.players {
+		if len(player.Snake) == 0 {
+			continue
+		}
+		if room.Distance(loc, player.Snake[0]) <= spawnHeadDistance {
+			return true
+		}
+	}
+	return false
+}
+
+// Distance returns the number of moves between two cells ignoring obstacles
+func (room *Room) Distance(a Location, b Location) int {
+	dx := abs(int(a.X) - int(b.X))
+	dy := abs(int(a.Y) - int(b.Y))
+	if room.settings.Wrap {
+		dx = min(dx, int(room.settings.Width)-dx)
+		dy = min(dy, int(room.settings.Height)-dy)
+	}
+	return dx + dy
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// UpdateTimers counts down respawns, spawn protection and food effects by tick
+// milliseconds, respawning every snake whose countdown is over. It reports
+// whether any timer changed.
+func (room *Room) UpdateTimers(tick uint16) bool {
+	changed := false
+	for _, player := range room.players {
+		if player.Protected > 0 {
+			player.Protected -= min(player.Protected, tick)
+			changed = true
+		}
+		player.Boost -= min(player.Boost, tick)
+		player.Ghost -= min(player.Ghost, tick)
+		player.Magnet -= min(player.Magnet, tick)
+		if player.Snake == nil && !player.Spectator {
+			player.RespawnIn -= min(player.RespawnIn, tick)
+			if player.RespawnIn == 0 {
+				room.Respawn(player)
+			}
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,151 @@
+package engine
+
+import "math/rand"
+
+// Food spawners
+const (
+	SPAWNER_UNIFORM uint8 = iota // Anywhere on the map
+	SPAWNER_CLUSTER              // Mostly next to other food
+	SPAWNER_FAR                  // Away from the heads of snakes
+	SPAWNER_POINTS               // On the food spawn points of the map file
+	SPAWNER_BURST                // Anywhere, and dead snakes turn into food
+)
+
+const (
+	clusterChance  = 75 // Percent of food spawning next to other food
+	clusterRadius  = 2
+	farSamples     = 10 // Cells tried to find one far from the snakes
+	burstFoodLimit = 20 // Most food a dead snake turns into
+)
+
+// FoodSpawner decides where food appears in a room
+type FoodSpawner interface {
+	// Place returns the cell of a new food, ok is false when there is no
+	// cell left for it
+	Place(room *Room) (loc Location, ok bool)
+	// Died is told about the body of every snake that died
+	Died(room *Room, body []Location)
+}
+
+func NewFoodSpawner(kind uint8) FoodSpawner {
+	switch kind {
+	case SPAWNER_CLUSTER:
+		return clusterSpawner{}
+	case SPAWNER_FAR:
+		return farSpawner{}
+	case SPAWNER_POINTS:
+		return pointsSpawner{}
+	case SPAWNER_BURST:
+		return burstSpawner{}
+	}
+	return uniformSpawner{}
+}
+
+type uniformSpawner struct{}
+
+func (uniformSpawner) Place(room *Room) (Location, bool) {
+	return room.FindLoc()
+}
+
+func (uniformSpawner) Died(room *Room, body []Location) {}
+
+type clusterSpawner struct{ uniformSpawner }
+
+func (spawner clusterSpawner) Place(room *Room) (Location, bool) {
+	if len(room.foods) == 0 || rand.Intn(100) >= clusterChance {
+		return spawner.uniformSpawner.Place(room)
+	}
+
+	foods := make([]Location, 0, len(room.foods))
+	for loc := range room.foods {
+		foods = append(foods, loc)
+	}
+	for try := 0; try < maxSpawnTries; try++ {
+		center := foods[rand.Intn(len(foods))]
+		x := int(center.X) + rand.Intn(clusterRadius*2+1) - clusterRadius
+		y := int(center.Y) + rand.Intn(clusterRadius*2+1) - clusterRadius
+		if x < 0 || y < 0 || x >= int(room.settings.Width) || y >= int(room.settings.Height) {
+			continue
+		}
+		loc := Location{X: uint8(x), Y: uint8(y)}
+		if room.roomMap[y][x] == CELL_EMPTY && room.InZone(loc) {
+			return loc, true
+		}
+	}
+	return spawner.uniformSpawner.Place(room)
+}
+
+type farSpawner struct{ uniformSpawner }
+
+func (farSpawner) Place(room *Room) (Location, bool) {
+	var best Location
+	bestDistance := -1
+	for i := 0; i < farSamples; i++ {
+		loc, ok := room.FindLoc()
+		if !ok {
+			return loc, false
+		}
+		distance := room.HeadDistance(loc)
+		if distance > bestDistance {
+			best, bestDistance = loc, distance
+		}
+	}
+	return best, true
+}
+
+type pointsSpawner struct{ uniformSpawner }
+
+func (spawner pointsSpawner) Place(room *Room) (Location, bool) {
+	if room.mapFile == nil || len(room.mapFile.Foods) == 0 {
+		return spawner.uniformSpawner.Place(room)
+	}
+	free := []Location{}
+	for _, loc := range room.mapFile.Foods {
+		if room.roomMap[loc.Y][loc.X] == CELL_EMPTY && room.InZone(loc) {
+			free = append(free, loc)
+		}
+	}
+	if len(free) == 0 {
+		return Location{}, false
+	}
+	return free[rand.Intn(len(free))], true
+}
+
+type burstSpawner struct{ uniformSpawner }
+
+func (burstSpawner) Died(room *Room, body []Location) {
+	for i, loc := range body {
+		if i == burstFoodLimit {
+			break
+		}
+		if room.roomMap[loc.Y][loc.X] == CELL_EMPTY && room.InZone(loc) {
+			room.foods[loc] = FOOD_NORMAL
+			room.SetCell(loc, CELL_FOOD)
+		}
+	}
+}
+
+// HeadDistance returns the distance from loc to the closest head, or the size
+// of the map when there is no snake
+func (room *Room) HeadDistance(loc Location) int {
+	closest := int(room.settings.Width) + int(room.settings.Height)
+	for _, player := range room.players {
+		if len(player.Snake) != 0 {
+			closest = min(closest, room.Distance(loc, player.Snake[0]))
+		}
+	}
+	return closest
+}
+
+// SpawnFoods turns the snakes that died since the last call into food as the
+// spawner of the room decides, then tops food up to one per player. Food
+// stops spawning when the board is full.
+func (room *Room) SpawnFoods() {
+	for _, body := range room.corpses {
+		room.spawner.Died(room, body)
+	}
+	room.corpses = nil
+
+	for len(room.foods) < int(room.playerNum) && room.SpawnFood() {
+	}
+}
@@ -0,0 +1,113 @@
+package engine
+
+const maxTeams = 4
+
+func (room *Room) SameTeam(a *Player, b *Player) bool {
+	return room.settings.Teams > 0 && a.Team == b.Team
+}
+
+// PassThrough reports whether two different snakes move through each other
+func (room *Room) PassThrough(a *Player, b *Player) bool {
+	return a != b && room.SameTeam(a, b) && !room.settings.FriendlyFire
+}
+
+// JoinTeam puts the player on the team they picked, or on the smallest team
+// when they picked none or a team the room doesn't have
+func (room *Room) JoinTeam(player *Player, team uint8) {
+	if room.settings.Teams == 0 {
+		player.Team = 0
+		return
+	}
+	if team > 0 && team <= room.settings.Teams {
+		player.Team = team
+		return
+	}
+
+	sizes := make([]int, room.settings.Teams+1)
+	for _, other := range room.players {
+		if other != player && other.Team > 0 && other.Team <= room.settings.Teams {
+			sizes[other.Team]++
+		}
+	}
+	player.Team = 1
+	for team := uint8(2); team <= room.settings.Teams; team++ {
+		if sizes[team] < sizes[player.Team] {
+			player.Team = team
+		}
+	}
+}
+
+// ChangeTeam lets a player switch team in the lobby
+func (room *Room) ChangeTeam(user *User, team uint8) bool {
+	room.playersMut.Lock()
+	defer room.playersMut.Unlock()
+
+	player, exist := room.players[user.ID]
+	if !exist || room.match.Phase != MATCH_LOBBY || team == 0 || team > room.settings.Teams {
+		return false
+	}
+	player.Team = team
+	return true
+}
+
+// TeamScores returns the sum of the points of every team, team 1 first
+func (room *Room) TeamScores() []uint32 {
+	scores := make([]uint32, room.settings.Teams)
+	for _, player := range room.players {
+		if player.Team > 0 && int(player.Team) <= len(scores) {
+			scores[player.Team-1] += player.Point
+		}
+	}
+	return scores
+}
+
+// BestTeams returns the players of every team sharing the highest score
+// together with that score
+func (room *Room) BestTeams() ([]*Player, uint32) {
+	scores := room.TeamScores()
+	var best uint32
+	for _, score := range scores {
+		best = max(best, score)
+	}
+	players := []*Player{}
+	for _, player := range room.players {
+		if player.Team > 0 && scores[player.Team-1] == best {
+			players = append(players, player)
+		}
+	}
+	return players, best
+}
+
+// TeamRoundOver checks the win condition of a team room, every player of the
+// winning team wins
+func (room *Room) TeamRoundOver() (winners []*Player, over bool) {
+	switch room.settings.WinCondition {
+	case WIN_POINTS:
+		best, score := room.BestTeams()
+		if len(best) != 0 && score >= uint32(room.settings.WinPoints) {
+			return best, true
+		}
+	case WIN_TIMER:
+		if room.match.TimeLeft == 0 {
+			best, _ := room.BestTeams()
+			return best, true
+		}
+	case WIN_LAST_ALIVE, WIN_ROYALE:
+		alive := make(map[uint8]bool)
+		for _, player := range room.players {
+			if !player.Spectator {
+				alive[player.Team] = true
+			}
+		}
+		if len(alive) <= 1 {
+			winners := []*Player{}
+			for _, player := range room.players {
+				if alive[player.Team] {
+					winners = append(winners, player)
+				}
+			}
+			return winners, true
+		}
+	}
+	return nil, false
+}
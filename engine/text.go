@@ -0,0 +1,40 @@
+package engine
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+const NAME_SIZE = 5 // Runes of a username
+
+// ValidName reports whether a username is 1 to NAME_SIZE characters that
+// can each be drawn on a cell of the map
+func ValidName(username string) bool {
+	count := utf8.RuneCountInString(username)
+	if count == 0 || count > NAME_SIZE {
+		return false
+	}
+	for _, r := range username {
+		if !ValidShape(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidShape reports whether a rune can be drawn on a cell of the map on its
+// own. Spaces, control and format characters and marks combining with the
+// rune before them can't.
+func ValidShape(r rune) bool {
+	return r != utf8.RuneError && utf8.ValidRune(r) && unicode.IsGraphic(r) && !unicode.IsSpace(r) && !unicode.IsMark(r)
+}
+
+// validText reports whether a message holds no control or format characters
+func validText(text string) bool {
+	for _, r := range text {
+		if r == utf8.RuneError || unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+			return false
+		}
+	}
+	return true
+}
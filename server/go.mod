@@ -1,3 +1,7 @@
 module server
 
 go 1.23.0
+
+require engine v0.0.0
+
+replace engine => ../engine
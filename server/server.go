@@ -9,6 +9,7 @@ import (
 	"crypto/sha256"
 	"crypto/x509"
 	"encoding/binary"
+	"engine"
 	"io"
 	"log"
 	"math/rand"
@@ -24,18 +25,9 @@ const (
 	TCP         = "tcp4"
 	BUFFER_SIZE = 2048
 	MAX_ROOMS   = 10
+	MAPS_DIR    = "maps"
 )
 
-type CommandRequest struct {
-	UserID     uint32
-	JoinRoom   bool
-	RoomID     uint8
-	ExitRoom   bool
-	Quit       bool
-	Username   [5]rune
-	SnakeShape rune
-}
-
 type CommandResponse struct {
 	IsSuccess bool
 	ExitRoom  bool
@@ -43,26 +35,27 @@ type CommandResponse struct {
 	Quit      bool
 }
 
-type MoveRequest struct {
-	UserID uint32
-	Move   rune
-}
+type (
+	CommandRequest = engine.CommandRequest
+	MoveRequest    = engine.MoveRequest
+)
 
 type User struct {
-	ID         uint32
-	RoomID     uint8
+	engine.User
 	UdpAddress *net.UDPAddr
 }
 
 var Users map[uint32]*User
-var Rooms map[uint8]*Room
+var Rooms map[uint8]*engine.Room
+var Maps map[string]*engine.MapFile
 var socketUDP *net.UDPConn
 var symmetricKeys map[string][]byte
 
 func main() {
 	Users = make(map[uint32]*User)
-	Rooms = make(map[uint8]*Room)
+	Rooms = make(map[uint8]*engine.Room)
 	symmetricKeys = make(map[string][]byte)
+	Maps = engine.LoadMaps(MAPS_DIR)
 
 	// Create UDP Listener
 	udpListenAddress, err := net.ResolveUDPAddr(UDP, net.JoinHostPort(SERVER_IP, UDP_PORT))
@@ -101,8 +94,18 @@ func readUDP(conn *net.UDPConn) {
 		go func(recBuffer []byte, addr *net.UDPAddr) {
 			key := symmetricKeys[udpAddr.String()]
 			move := decodeMove(recBuffer, key)
-			room := Rooms[Users[move.UserID].RoomID]
-			room.mainChannel <- move
+			// Moves may still arrive after their user left
+			user, exist := Users[move.UserID]
+			if !exist {
+				return
+			}
+			if move.Ping {
+				conn.WriteToUDP(engine.Pong(move), addr)
+				return
+			}
+			if room, exist := Rooms[user.RoomID]; exist {
+				room.Move(move)
+			}
 		}(receiveBuffer[:receiveLength], udpAddr)
 	}
 }
@@ -163,34 +166,29 @@ func readTCP(conn *net.TCPConn) {
 		response := CommandResponse{false, false, false, false}
 		if command.JoinRoom {
 			room, roomExist := Rooms[command.RoomID]
-			if roomExist {
-				response.IsSuccess = room.AddPlayer(&user, strings.ReplaceAll(string(command.Username[:]), "\x00", ""), command.SnakeShape)
-				response.JoinRoom = true
-			} else {
-				room := Room{
-					ID:          command.RoomID,
-					playerNum:   0,
-					mainChannel: make(chan MoveRequest, 1),
-					playerMoves: make(map[uint32]chan MoveRequest),
-					players:     make(map[uint32]*Player),
-					foods:       make(map[Location]Location),
-				}
-				Rooms[command.RoomID] = &room
-				room.InitialMap()
-
-				response.IsSuccess = room.AddPlayer(&user, strings.ReplaceAll(string(command.Username[:]), "\x00", ""), command.SnakeShape)
-				response.JoinRoom = true
-				go room.Start()
+			if !roomExist {
+				room = engine.NewRoom(command.RoomID, command.Settings, Maps, sendSnapshot)
+			}
+			response.IsSuccess = room.AddPlayer(&user.User, strings.ReplaceAll(string(command.Username[:]), "\x00", ""), command.SnakeShape, command.Team, command.Color)
+			response.JoinRoom = true
+			// A new room is only kept once its first player is in, an empty
+			// room would never stop
+			if response.IsSuccess && !roomExist {
+				Rooms[command.RoomID] = room
+				go func() {
+					room.Start()
+					delete(Rooms, room.ID)
+				}()
 			}
 		} else if command.ExitRoom {
-			room := Rooms[user.RoomID]
-			room.ExitRoom(&user)
+			if room, exist := Rooms[user.RoomID]; exist {
+				room.ExitRoom(&user.User)
+			}
 			response.IsSuccess = true
 			response.ExitRoom = true
 		} else if command.Quit {
-			if user.RoomID != 0 {
-				room := Rooms[user.RoomID]
-				room.ExitRoom(&user)
+			if room, exist := Rooms[user.RoomID]; exist {
+				room.ExitRoom(&user.User)
 			}
 
 			response.IsSuccess = true
@@ -198,12 +196,22 @@ func readTCP(conn *net.TCPConn) {
 			conn.Write(encodeCommandResponse(response, symmetricKey))
 
 			break
+		} else if room, exist := Rooms[user.RoomID]; exist {
+			// Lobby and host commands
+			response.IsSuccess = room.Command(&user.User, command)
 		}
 
 		conn.Write(encodeCommandResponse(response, symmetricKey))
 	}
 }
 
+// sendSnapshot sends a snapshot of a room to a player
+func sendSnapshot(userID uint32, snapshot []byte) {
+	if user, exist := Users[userID]; exist {
+		socketUDP.WriteToUDP(snapshot, user.UdpAddress)
+	}
+}
+
 func decodeCommandRequest(bytesCommand []byte, key []byte) CommandRequest {
 	var command CommandRequest
 	bytesReader := bytes.NewReader(decryptMessage(bytesCommand, key))